@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/yaegashi/pswa/config"
+)
+
+// APIKeyHeader returns the request header name for API keys.
+func (a *Auth) APIKeyHeader() string {
+	if h := a.Config().APIKeyHeader; h != "" {
+		return h
+	}
+	return config.DefaultAPIKeyHeader
+}
+
+// APIKeyIdentity returns the identity of the API key in the request header of r,
+// or nil if there's no API key.  It returns an error for an unknown or expired key.
+func (a *Auth) APIKeyIdentity(r *http.Request) (*Identity, error) {
+	key := r.Header.Get(a.APIKeyHeader())
+	if key == "" {
+		return nil, nil
+	}
+	sum := sha256.Sum256([]byte(key))
+	var found *config.APIKey
+	for _, k := range a.Config().APIKeys {
+		// Compare with all keys in constant time
+		if subtle.ConstantTimeCompare(sum[:], k.Sum) == 1 {
+			found = k
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("Unknown API key")
+	}
+	if found.Expired(time.Now()) {
+		return nil, fmt.Errorf("API key %q expired at %s", found.ID, found.Expires)
+	}
+	name := found.Name
+	if name == "" {
+		name = found.ID
+	}
+	identity := &Identity{
+		Typ:   "apikey",
+		Id:    found.ID,
+		Name:  name,
+		Roles: append([]string{"authenticated"}, found.Roles...),
+	}
+	sort.Strings(identity.Roles)
+	return identity, nil
+}
@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+const BearerPrefix = "Bearer "
+
+type AppClaims struct {
+	AppID    string   `json:"azp"`
+	AppIDV1  string   `json:"appid"`
+	Id       string   `json:"oid"`
+	Subject  string   `json:"sub"`
+	IdType   string   `json:"idtyp"`
+	Nonce    string   `json:"nonce"`
+	TenantID string   `json:"tid"`
+	Roles    []string `json:"roles"`
+	Scope    string   `json:"scp"`
+}
+
+// AppIdentity returns the identity of the daemon app authenticated with the bearer
+// access token of r, or nil if there's no bearer token or appTokens is not configured.
+func (a *Auth) AppIdentity(r *http.Request) (*Identity, error) {
+	h := r.Header.Get("Authorization")
+	if !strings.HasPrefix(h, BearerPrefix) {
+		return nil, nil
+	}
+	appTokens := a.Config().AppTokens
+	if appTokens == nil || a.AppTokenVerifier == nil {
+		return nil, nil
+	}
+	token, err := a.AppTokenVerifier.Verify(r.Context(), strings.TrimPrefix(h, BearerPrefix))
+	if err != nil {
+		return nil, err
+	}
+	audiences := appTokens.Audiences
+	if audiences == nil {
+		audiences = []string{a.OAuth2Config.ClientID}
+	}
+	found := false
+	for _, aud := range token.Audience {
+		for _, want := range audiences {
+			if strings.EqualFold(aud, want) {
+				found = true
+			}
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("Token audience %v not in %v", token.Audience, audiences)
+	}
+	var claims AppClaims
+	err = token.Claims(&claims)
+	if err != nil {
+		return nil, err
+	}
+	err = a.CheckIssuer(token.Issuer, claims.TenantID)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Scope != "" {
+		return nil, fmt.Errorf("Delegated user tokens not accepted")
+	}
+	if claims.Nonce != "" {
+		return nil, fmt.Errorf("ID tokens not accepted")
+	}
+	// App-only tokens have idtyp=app if the optional claim is configured, and sub is always oid of the app
+	if claims.IdType != "app" && (claims.Id == "" || claims.Id != claims.Subject) {
+		return nil, fmt.Errorf("Non-app tokens not accepted")
+	}
+	appID := claims.AppID
+	if appID == "" {
+		appID = claims.AppIDV1
+	}
+	members := []string{strings.ToLower(appID), strings.ToLower(claims.Id)}
+	roles := a.Config().MemberRoles(members)
+	for _, role := range claims.Roles {
+		roles = append(roles, strings.ToLower(role))
+	}
+	identity := &Identity{
+		Typ:      "app",
+		Id:       appID,
+		Name:     appID,
+		Roles:    roles,
+		TenantID: strings.ToLower(claims.TenantID),
+	}
+	sort.Strings(identity.Roles)
+	err = a.CheckSignIn(identity, false)
+	if err != nil {
+		return nil, err
+	}
+	return identity, nil
+}
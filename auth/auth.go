@@ -6,8 +6,11 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/gorilla/securecookie"
 	"github.com/gorilla/sessions"
 	"github.com/yaegashi/pswa/config"
 	"golang.org/x/oauth2"
@@ -30,28 +33,51 @@ const (
 type Auth struct {
 	Provider              *oidc.Provider
 	Verifier              *oidc.IDTokenVerifier
+	AppTokenVerifier      *oidc.IDTokenVerifier
 	OAuth2Config          *oauth2.Config
 	OAuth2AuthCodeOptions []oauth2.AuthCodeOption
-	Config                *config.Config
 	SessionStore          sessions.Store
+	SessionName           string
+	TokenCodecs           []securecookie.Codec
+	DeviceAuthURL         string
 	EasyAuth              bool
+	DevAuth               bool
+	MultiTenant           bool
+	config                atomic.Pointer[config.Config]
+	basicCache            sync.Map
 }
 
 func New(cfg *config.Config, ss sessions.Store) *Auth {
-	return &Auth{
-		Config:       cfg,
+	a := &Auth{
 		SessionStore: ss,
+		SessionName:  SessionCookieName,
 		EasyAuth:     strings.ToLower(os.Getenv(EasyAuthAppSettingsEnvName)) == "true",
 	}
+	a.SetConfig(cfg)
+	return a
+}
+
+func (a *Auth) Config() *config.Config {
+	return a.config.Load()
+}
+
+func (a *Auth) SetConfig(cfg *config.Config) {
+	a.config.Store(cfg)
 }
 
 func (a *Auth) ConfigureOIDC(tenantID, clientID, clientSecret, redirectURI, authParams string) error {
+	ctx := context.Background()
+	multiTenant := MultiTenant(tenantID)
+	if multiTenant {
+		// The discovery document of multi-tenant authorities has the issuer template with {tenantid}
+		ctx = oidc.InsecureIssuerURLContext(ctx, fmt.Sprintf(FormatAADBaseURL, "{tenantid}"))
+	}
 	baseURL := fmt.Sprintf(FormatAADBaseURL, tenantID)
-	provider, err := oidc.NewProvider(context.Background(), baseURL)
+	provider, err := oidc.NewProvider(ctx, baseURL)
 	if err != nil {
 		return err
 	}
-	verifier := provider.Verifier(&oidc.Config{ClientID: clientID})
+	verifier := provider.Verifier(&oidc.Config{ClientID: clientID, SkipIssuerCheck: multiTenant})
 	var authCodeOptions []oauth2.AuthCodeOption
 	for _, p := range strings.Split(authParams, "&") {
 		s := strings.SplitN(p, "=", 2)
@@ -59,7 +85,14 @@ func (a *Auth) ConfigureOIDC(tenantID, clientID, clientSecret, redirectURI, auth
 			authCodeOptions = append(authCodeOptions, oauth2.SetAuthURLParam(s[0], s[1]))
 		}
 	}
+	var providerClaims struct {
+		DeviceAuthURL string `json:"device_authorization_endpoint"`
+	}
+	provider.Claims(&providerClaims)
 	a.Provider = provider
+	a.DeviceAuthURL = providerClaims.DeviceAuthURL
+	a.AppTokenVerifier = provider.Verifier(&oidc.Config{SkipClientIDCheck: true, SkipIssuerCheck: multiTenant})
+	a.MultiTenant = multiTenant
 	a.Verifier = verifier
 	a.OAuth2Config = &oauth2.Config{
 		ClientID:     clientID,
@@ -78,8 +111,25 @@ func (a *Auth) RegisterHandlers(mux *http.ServeMux) {
 	mux.HandleFunc(LoginHandlerPath, a.LoginHandler)
 	mux.HandleFunc(LogoutHandlerPath, a.LogoutHandler)
 	mux.HandleFunc(CallbackHandlerPath, a.CallbackHandler)
+	mux.HandleFunc(DeviceHandlerPath, a.DeviceHandler)
+	mux.HandleFunc(ImpersonateHandlerPath, a.ImpersonateHandler)
+	mux.HandleFunc(DeviceTokenHandlerPath, a.DeviceTokenHandler)
 	mux.HandleFunc(AltLoginHandlerPath, a.LoginHandler)
 	mux.HandleFunc(AltLogoutHandlerPath, a.LogoutHandler)
 	mux.HandleFunc(AltCallbackHandlerPath, a.CallbackHandler)
-	mux.HandleFunc(AltIdentityHandlerPath, a.IdentityHandler)
+	mux.HandleFunc(AltIdentityHandlerPath, a.AltIdentityHandler)
+	if a.DevAuth {
+		mux.HandleFunc(DevLoginHandlerPath, a.DevLoginHandler)
+	}
+}
+
+// RequestOAuth2Config returns the OAuth2 config for r.
+// Without the redirect URI configured, it's the callback of the request host.
+func (a *Auth) RequestOAuth2Config(r *http.Request) *oauth2.Config {
+	if a.OAuth2Config.RedirectURL != "" {
+		return a.OAuth2Config
+	}
+	c := *a.OAuth2Config
+	c.RedirectURL = "https://" + r.Host + CallbackHandlerPath
+	return &c
 }
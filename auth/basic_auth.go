@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// BasicIdentity returns the identity of the user authenticated with HTTP basic auth,
+// or nil if there are no credentials in r or basicAuth is not configured.
+func (a *Auth) BasicIdentity(r *http.Request) (*Identity, error) {
+	user, password, ok := r.BasicAuth()
+	if !ok {
+		return nil, nil
+	}
+	cfg := a.Config()
+	if cfg.BasicAuth == nil {
+		return nil, nil
+	}
+	hash, ok := cfg.BasicAuth.Users[user]
+	if !ok {
+		// Take as long as a wrong password not to reveal which users exist
+		bcrypt.CompareHashAndPassword(cfg.BasicAuth.DummyHash, []byte(password))
+		return nil, fmt.Errorf("Unknown user %q", user)
+	}
+	// bcrypt is slow by design: remember verified credentials for subsequent requests
+	key := sha256.Sum256([]byte(user + "\x00" + password + "\x00" + string(hash)))
+	if _, ok := a.basicCache.Load(key); !ok {
+		err := bcrypt.CompareHashAndPassword(hash, []byte(password))
+		if err != nil {
+			return nil, fmt.Errorf("Wrong password for user %q", user)
+		}
+		a.basicCache.Store(key, struct{}{})
+	}
+	return &Identity{
+		Typ:   "basic",
+		Id:    user,
+		Name:  user,
+		Roles: cfg.MemberRoles([]string{strings.ToLower(user)}),
+	}, nil
+}
@@ -1,13 +1,16 @@
 package auth
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"html"
 	"net/http"
 	"strings"
 
+	"github.com/coreos/go-oidc/v3/oidc"
 	"github.com/yaegashi/pswa/logging"
+	"golang.org/x/oauth2"
 )
 
 const (
@@ -31,10 +34,72 @@ type Claims struct {
 	Name         string                     `json:"name"`
 	Email        string                     `json:"email"`
 	Groups       []string                   `json:"groups"`
+	TenantID     string                     `json:"tid"`
+	AMR          []string                   `json:"amr"`
+	ACR          string                     `json:"acr"`
+	AuthTime     int64                      `json:"auth_time"`
+	Acct         *int                       `json:"acct"`
 	ClaimNames   ClaimNames                 `json:"_claim_names"`
 	ClaimSources map[string]json.RawMessage `json:"_claim_sources"`
 }
 
+// CheckIssuer validates the issuer of tokens from the multi-tenant authorities.
+func (a *Auth) CheckIssuer(issuer, tenantID string) error {
+	if a.MultiTenant && issuer != fmt.Sprintf(FormatAADBaseURL, tenantID) {
+		return fmt.Errorf("Issuer %q does not match tenant %q", issuer, tenantID)
+	}
+	return nil
+}
+
+// ClaimsIdentity returns the identity of the ID token claims.
+// It makes a graph member groups request if there's no groups claim.
+func (a *Auth) ClaimsIdentity(ctx context.Context, idToken *oidc.IDToken, claims *Claims, oauth2Token *oauth2.Token) (*Identity, []string, error) {
+	logger := logging.Logger(ctx).Sugar()
+
+	typ := "user"
+	id := claims.Id
+	name := claims.Name
+	email := claims.Email
+	groups := claims.Groups
+
+	members := make([]string, len(groups)+1)
+	members[0] = strings.ToLower(id)
+	for i, g := range groups {
+		members[i+1] = strings.ToLower(g)
+	}
+
+	var graphGroups []string
+	var graphErr error
+	if groups == nil {
+		logger.Info("No groups claim found.  Making a graph member groups request...")
+		graphGroups, graphErr = GraphMemberGroupsRequest(ctx, oauth2Token)
+		if graphErr == nil {
+			for _, g := range graphGroups {
+				members = append(members, strings.ToLower(g))
+			}
+		} else {
+			logger.Error(graphErr)
+		}
+	}
+
+	identity := &Identity{
+		Typ:   typ,
+		Id:    id,
+		Name:  name,
+		Email: email,
+		Roles: a.Config().MemberRoles(members),
+
+		TenantID: strings.ToLower(claims.TenantID),
+		AMR:      claims.AMR,
+		ACR:      claims.ACR,
+		AuthTime: claims.AuthTime,
+	}
+	if identity.AuthTime == 0 {
+		identity.AuthTime = idToken.IssuedAt.Unix()
+	}
+	return identity, graphGroups, graphErr
+}
+
 func htmlDump(v any) string {
 	b, _ := json.MarshalIndent(v, "", "  ")
 	return html.EscapeString(string(b))
@@ -59,9 +124,11 @@ func (a *Auth) CallbackHandler(w http.ResponseWriter, r *http.Request) {
 		sessionReturn = "/"
 	}
 	sessionDebug, _ := session.Values[DebugValueName].(string)
+	sessionStepUp, _ := session.Values[StepUpValueName].(int64)
 	delete(session.Values, StateValueName)
 	delete(session.Values, ReturnValueName)
 	delete(session.Values, DebugValueName)
+	delete(session.Values, StepUpValueName)
 
 	if r.FormValue(ErrorValueName) != "" {
 		http.Error(w, fmt.Sprintf("Error: %s\n%s\n", r.FormValue(ErrorValueName), r.FormValue(ErrorDescriptionValueName)), http.StatusBadRequest)
@@ -77,7 +144,7 @@ func (a *Auth) CallbackHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Unmatched state cookie", http.StatusBadRequest)
 		return
 	}
-	oauth2Token, err := a.OAuth2Config.Exchange(ctx, formCode)
+	oauth2Token, err := a.RequestOAuth2Config(r).Exchange(ctx, formCode)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -99,38 +166,19 @@ func (a *Auth) CallbackHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	typ := "user"
-	id := claims.Id
-	name := claims.Name
-	email := claims.Email
-	groups := claims.Groups
-
-	members := make([]string, len(groups)+1)
-	members[0] = strings.ToLower(id)
-	for i, g := range groups {
-		members[i+1] = strings.ToLower(g)
-	}
-
-	var graphGroups []string
-	var graphErr error
-	if groups == nil {
-		logger.Info("No groups claim found.  Making a graph member groups request...")
-		graphGroups, graphErr = GraphMemberGroupsRequest(ctx, oauth2Token)
-		if graphErr == nil {
-			for _, g := range graphGroups {
-				members = append(members, strings.ToLower(g))
-			}
-		} else {
-			logger.Error(graphErr)
-		}
+	err = a.CheckIssuer(idToken.Issuer, claims.TenantID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	identity := &Identity{
-		Typ:   typ,
-		Id:    id,
-		Name:  name,
-		Email: email,
-		Roles: a.Config.MemberRoles(members),
+	identity, graphGroups, graphErr := a.ClaimsIdentity(ctx, idToken, &claims, oauth2Token)
+	identity.StepUp = sessionStepUp
+	err = a.CheckSignIn(identity, claims.Acct != nil && *claims.Acct == 1)
+	if err != nil {
+		logger.Warnf("Sign-in denied: %s: %#v", err, identity)
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
 	}
 	logger.Infof("Identity: %#v", identity)
 
@@ -157,7 +205,7 @@ func (a *Auth) CallbackHandler(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, `<p>Identity to be stored in the cookie:</p><pre>%s</pre>`, htmlDump(identity))
 
 	// PSWA Configuration
-	fmt.Fprintf(w, `<p>PSWA configuration:</p><pre>%s</pre>`, htmlDump(a.Config))
+	fmt.Fprintf(w, `<p>PSWA configuration:</p><pre>%s</pre>`, htmlDump(a.Config().Redacted()))
 
 	// Decoded ID token
 	fmt.Fprintf(w, `<p>Decoded ID token (name, email, groups):</p><pre>%s</pre>`, htmlDump(claims))
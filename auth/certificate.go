@@ -0,0 +1,35 @@
+package auth
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/yaegashi/pswa/config"
+)
+
+// CertificateIdentity returns the identity of the verified client certificate of r
+// mapped by clientCertificates of the config, or nil if none.
+func (a *Auth) CertificateIdentity(r *http.Request) *Identity {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+		return nil
+	}
+	cert := r.TLS.VerifiedChains[0][0]
+	for _, cc := range a.Config().ClientCertificates {
+		if !cc.Match(cert) {
+			continue
+		}
+		name := cc.Name
+		if name == "" {
+			name = cert.Subject.CommonName
+		}
+		identity := &Identity{
+			Typ:   "cert",
+			Id:    config.Fingerprint(cert),
+			Name:  name,
+			Roles: append([]string{"authenticated"}, cc.Roles...),
+		}
+		sort.Strings(identity.Roles)
+		return identity
+	}
+	return nil
+}
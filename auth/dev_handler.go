@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/yaegashi/pswa/logging"
+)
+
+const DevLoginHandlerPath = "/.auth/login/dev"
+
+const devLoginForm = `<!DOCTYPE html>
+<html>
+<head><title>PSWA Development Login</title></head>
+<body>
+<h1>PSWA Development Login</h1>
+<p><b>Development identity provider: anyone can sign in as any user with any roles.  NEVER enable it in production!</b></p>
+<form method="post">
+<input type="hidden" name="return" value="%s">
+<p><label>User ID <input name="id" value="dev-user" required></label></p>
+<p><label>Name <input name="name" value="Development User"></label></p>
+<p><label>Email <input name="email" value="dev-user@example.com"></label></p>
+<p><label>Roles (comma-separated) <input name="roles"></label></p>
+<p><input type="submit" value="Login"></p>
+</form>
+</body>
+</html>
+`
+
+// DevLoginHandler issues a session with the identity entered in the form.
+// It's registered only if the development identity provider is enabled.
+func (a *Auth) DevLoginHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-cache")
+
+	sessionReturn := localPath(r.FormValue(ReturnValueName))
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+		fmt.Fprintf(w, devLoginForm, html.EscapeString(sessionReturn))
+		return
+	}
+
+	id := strings.TrimSpace(r.FormValue("id"))
+	if id == "" {
+		http.Error(w, "No user ID", http.StatusBadRequest)
+		return
+	}
+	roles := a.Config().MemberRoles([]string{strings.ToLower(id)})
+	for _, role := range strings.Split(r.FormValue("roles"), ",") {
+		role = strings.ToLower(strings.TrimSpace(role))
+		if role != "" && role != "anonymous" && role != "authenticated" {
+			roles = append(roles, role)
+		}
+	}
+	sort.Strings(roles)
+	identity := &Identity{
+		Typ:   "dev",
+		Id:    id,
+		Name:  r.FormValue("name"),
+		Email: r.FormValue("email"),
+		Roles: roles,
+	}
+	logging.Logger(r.Context()).Sugar().Warnf("Development identity provider sign-in: %#v", identity)
+
+	session := a.Session(r)
+	session.Values[IdentityValueName] = identity
+	err := session.Save(r, w)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, sessionReturn, http.StatusFound)
+}
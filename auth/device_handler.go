@@ -0,0 +1,175 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/yaegashi/pswa/logging"
+	"golang.org/x/oauth2"
+)
+
+const (
+	DeviceHandlerPath      = "/.auth/pswa/device"
+	DeviceTokenHandlerPath = "/.auth/pswa/device/token"
+	DeviceCodeValueName    = "device_code"
+	DeviceGrantType        = "urn:ietf:params:oauth:grant-type:device_code"
+	DeviceTokenName        = "PSWADeviceToken"
+	DeviceTokenLifetime    = 12 * time.Hour
+)
+
+// NewTokenCodecs returns codecs of pswa bearer tokens signed with the session key.
+func NewTokenCodecs(key []byte) []securecookie.Codec {
+	s := securecookie.New(key, nil)
+	s.MaxAge(int(DeviceTokenLifetime / time.Second))
+	return []securecookie.Codec{s}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// postForm posts values to the provider endpoint and decodes the JSON response.
+func postForm(ctx context.Context, endpoint string, values url.Values) (int, map[string]any, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(values.Encode()))
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer res.Body.Close()
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		return 0, nil, err
+	}
+	var m map[string]any
+	err = json.Unmarshal(b, &m)
+	if err != nil {
+		return 0, nil, fmt.Errorf("Bad response %s: %s", res.Status, string(b))
+	}
+	return res.StatusCode, m, nil
+}
+
+// DeviceHandler starts the device authorization grant with the provider.
+func (a *Auth) DeviceHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-cache")
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if a.OAuth2Config == nil || a.DeviceAuthURL == "" {
+		http.Error(w, "Device authorization grant not available", http.StatusNotFound)
+		return
+	}
+	values := url.Values{
+		"client_id": {a.OAuth2Config.ClientID},
+		"scope":     {strings.Join(a.OAuth2Config.Scopes, " ")},
+	}
+	status, m, err := postForm(r.Context(), a.DeviceAuthURL, values)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, status, m)
+}
+
+// DeviceTokenHandler polls the provider with the device code,
+// and issues a pswa bearer token when the user completes the sign-in.
+func (a *Auth) DeviceTokenHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-cache")
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if a.OAuth2Config == nil || a.DeviceAuthURL == "" {
+		http.Error(w, "Device authorization grant not available", http.StatusNotFound)
+		return
+	}
+	ctx := r.Context()
+	logger := logging.Logger(ctx).Sugar()
+	deviceCode := r.FormValue(DeviceCodeValueName)
+	if deviceCode == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request", "error_description": "No device_code"})
+		return
+	}
+	values := url.Values{
+		"grant_type":  {DeviceGrantType},
+		"client_id":   {a.OAuth2Config.ClientID},
+		"device_code": {deviceCode},
+	}
+	status, m, err := postForm(ctx, a.OAuth2Config.Endpoint.TokenURL, values)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	if _, ok := m["error"]; ok || status != http.StatusOK {
+		// authorization_pending, slow_down, expired_token and so on
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": m["error"], "error_description": m["error_description"]})
+		return
+	}
+	rawIDToken, _ := m["id_token"].(string)
+	accessToken, _ := m["access_token"].(string)
+	idToken, err := a.Verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var claims Claims
+	err = idToken.Claims(&claims)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	err = a.CheckIssuer(idToken.Issuer, claims.TenantID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	identity, _, _ := a.ClaimsIdentity(ctx, idToken, &claims, &oauth2.Token{AccessToken: accessToken})
+	err = a.CheckSignIn(identity, claims.Acct != nil && *claims.Acct == 1)
+	if err != nil {
+		logger.Warnf("Sign-in denied: %s: %#v", err, identity)
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	logger.Infof("Device identity: %#v", identity)
+
+	// Sign the token with the request host in its name so that other hosts reject it
+	token, err := securecookie.EncodeMulti(DeviceTokenName+":"+r.Host, identity, a.TokenCodecs...)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": token,
+		"token_type":   "Bearer",
+		"expires_in":   int(DeviceTokenLifetime / time.Second),
+	})
+}
+
+// TokenIdentity returns the identity of the pswa bearer token in r, or nil if none.
+func (a *Auth) TokenIdentity(r *http.Request) *Identity {
+	h := r.Header.Get("Authorization")
+	if !strings.HasPrefix(h, BearerPrefix) || len(a.TokenCodecs) == 0 {
+		return nil
+	}
+	var identity *Identity
+	err := securecookie.DecodeMulti(DeviceTokenName+":"+r.Host, strings.TrimPrefix(h, BearerPrefix), &identity, a.TokenCodecs...)
+	if err != nil {
+		return nil
+	}
+	return identity
+}
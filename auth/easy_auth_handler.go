@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"html"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/yaegashi/pswa/logging"
@@ -56,8 +57,12 @@ func (a *Auth) EasyAuthHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, fmt.Sprintf("JSON decode failed: %s", err), http.StatusInternalServerError)
 	}
 	principalMap := map[string]any{}
+	principalValues := map[string][]string{}
 	for _, claim := range principal.Claims {
 		principalMap[claim.Typ] = claim.Val
+		if val, ok := claim.Val.(string); ok {
+			principalValues[claim.Typ] = append(principalValues[claim.Typ], val)
+		}
 	}
 
 	typ := "user"
@@ -68,6 +73,15 @@ func (a *Auth) EasyAuthHandler(w http.ResponseWriter, r *http.Request) {
 	name, _ := principalMap["name"].(string)
 	email, _ := principalMap["http://schemas.xmlsoap.org/ws/2005/05/identity/claims/emailaddress"].(string)
 	groups, _ := principalMap["groups"].([]string)
+	tenantID, _ := principalMap["http://schemas.microsoft.com/identity/claims/tenantid"].(string)
+	// Multi-valued claims like amr are repeated claims of the same type
+	amr := principalValues["http://schemas.microsoft.com/claims/authnmethodsreferences"]
+	acr, _ := principalMap["http://schemas.microsoft.com/claims/authnclassreference"].(string)
+	acct, _ := principalMap["acct"].(string)
+	authTime, _ := principalMap["auth_time"].(string)
+	if authTime == "" {
+		authTime, _ = principalMap["iat"].(string)
+	}
 
 	members := make([]string, len(groups)+1)
 	members[0] = strings.ToLower(id)
@@ -94,7 +108,20 @@ func (a *Auth) EasyAuthHandler(w http.ResponseWriter, r *http.Request) {
 		Id:    id,
 		Name:  name,
 		Email: email,
-		Roles: a.Config.MemberRoles(members),
+		Roles: a.Config().MemberRoles(members),
+
+		TenantID: strings.ToLower(tenantID),
+		ACR:      acr,
+	}
+	identity.AuthTime, _ = strconv.ParseInt(authTime, 10, 64)
+	for _, m := range amr {
+		identity.AMR = append(identity.AMR, strings.Split(m, ",")...)
+	}
+	err = a.CheckSignIn(identity, acct == "1")
+	if err != nil {
+		logger.Warnf("Sign-in denied: %s: %#v", err, identity)
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
 	}
 	logger.Infof("Identity: %#v", identity)
 
@@ -147,7 +174,7 @@ func (a *Auth) EasyAuthHandler(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, `<p>Identity to be stored in the cookie:</p><pre>%s</pre>`, htmlDump(identity))
 
 	// PSWA Configuration
-	fmt.Fprintf(w, `<p>PSWA configuration:</p><pre>%s</pre>`, htmlDump(a.Config))
+	fmt.Fprintf(w, `<p>PSWA configuration:</p><pre>%s</pre>`, htmlDump(a.Config().Redacted()))
 
 	// Decoded prinicipal
 	fmt.Fprintf(w, `<p>Decoded principal:</p><pre>%s</pre>`, htmlDump(principal))
@@ -5,6 +5,15 @@ import (
 	"net/http"
 )
 
+// ClientPrincipal is the identity in the format of Azure Static Web Apps.
+type ClientPrincipal struct {
+	IdentityProvider string           `json:"identityProvider"`
+	UserID           string           `json:"userId"`
+	UserDetails      string           `json:"userDetails"`
+	UserRoles        []string         `json:"userRoles"`
+	Claims           []PrincipalClaim `json:"claims"`
+}
+
 func (a *Auth) IdentityHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Cache-Control", "no-cache")
 	body := []byte("null")
@@ -18,3 +27,55 @@ func (a *Auth) IdentityHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	w.Write(body)
 }
+
+// NewClientPrincipal converts identity to the format of Azure Static Web Apps.
+func NewClientPrincipal(identity *Identity) *ClientPrincipal {
+	if identity == nil {
+		return nil
+	}
+	provider := identity.Typ
+	switch identity.Typ {
+	case "user", "app":
+		provider = "aad"
+	}
+	details := identity.Email
+	if details == "" {
+		details = identity.Name
+	}
+	p := &ClientPrincipal{
+		IdentityProvider: provider,
+		UserID:           identity.Id,
+		UserDetails:      details,
+		UserRoles:        append([]string{"anonymous"}, identity.Roles...),
+		Claims:           []PrincipalClaim{},
+	}
+	add := func(typ string, val any) {
+		if val != "" {
+			p.Claims = append(p.Claims, PrincipalClaim{Typ: typ, Val: val})
+		}
+	}
+	add("name", identity.Name)
+	add("http://schemas.xmlsoap.org/ws/2005/05/identity/claims/emailaddress", identity.Email)
+	add("http://schemas.microsoft.com/identity/claims/objectidentifier", identity.Id)
+	add("http://schemas.microsoft.com/identity/claims/tenantid", identity.TenantID)
+	for _, amr := range identity.AMR {
+		add("http://schemas.microsoft.com/claims/authnmethodsreferences", amr)
+	}
+	if identity.Impersonator != nil {
+		add("pswa_impersonator", identity.Impersonator.Id)
+	}
+	return p
+}
+
+// AltIdentityHandler returns the identity in the format of Azure Static Web Apps
+// for apps using /.auth/me.
+func (a *Auth) AltIdentityHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-cache")
+	var identity *Identity
+	if a != nil {
+		identity = a.Identity(r)
+	}
+	body, _ := json.Marshal(map[string]any{"clientPrincipal": NewClientPrincipal(identity)})
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"html"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/yaegashi/pswa/logging"
+)
+
+const ImpersonateHandlerPath = "/.auth/pswa/impersonate"
+
+const impersonateForm = `<!DOCTYPE html>
+<html>
+<head><title>PSWA Impersonation</title></head>
+<body>
+<h1>PSWA Impersonation</h1>
+<p>Signed in as %[1]s.  %[2]s</p>
+<form method="post">
+<input type="hidden" name="csrf" value="%[3]s">
+<input type="hidden" name="return" value="%[4]s">
+<p><label>User ID <input name="id"></label> (optional)</p>
+<p><label>Roles (comma-separated) <input name="roles"></label></p>
+<p><input type="submit" value="Impersonate"></p>
+</form>
+<form method="post">
+<input type="hidden" name="csrf" value="%[3]s">
+<input type="hidden" name="return" value="%[4]s">
+<input type="hidden" name="revert" value="true">
+<p><input type="submit" value="Revert"></p>
+</form>
+</body>
+</html>
+`
+
+// localPath returns s if it is a path on this site, or "/" otherwise to prevent open redirects.
+func localPath(s string) string {
+	if !strings.HasPrefix(s, "/") || strings.HasPrefix(s, "//") || strings.HasPrefix(s, "/\\") {
+		return "/"
+	}
+	return s
+}
+
+// ImpersonateHandler lets users with adminRole assume another user ID and roles in the session.
+// The original identity is kept in the impersonator field for reverting.
+func (a *Auth) ImpersonateHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-cache")
+
+	adminRole := a.Config().AdminRole
+	if adminRole == "" {
+		http.NotFound(w, r)
+		return
+	}
+	session := a.Session(r)
+	identity, _ := session.Values[IdentityValueName].(*Identity)
+	if identity == nil {
+		http.Error(w, "Sign-in required", http.StatusUnauthorized)
+		return
+	}
+	original := identity
+	if identity.Impersonator != nil {
+		original = identity.Impersonator
+	}
+	if !original.HasRole(adminRole) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	sessionReturn := localPath(r.FormValue(ReturnValueName))
+	sessionCSRF, _ := session.Values[CSRFValueName].(string)
+
+	if r.Method != http.MethodPost {
+		if sessionCSRF == "" {
+			sessionCSRF = uuid.New().String()
+			session.Values[CSRFValueName] = sessionCSRF
+			err := session.Save(r, w)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		status := "Not impersonating."
+		if identity.Impersonator != nil {
+			status = fmt.Sprintf("Impersonating %q with roles %v.", identity.Id, identity.Roles)
+		}
+		w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+		fmt.Fprintf(w, impersonateForm, html.EscapeString(fmt.Sprintf("%q", original.Id)), html.EscapeString(status), html.EscapeString(sessionCSRF), html.EscapeString(sessionReturn))
+		return
+	}
+
+	formCSRF := r.FormValue(CSRFValueName)
+	if sessionCSRF == "" || subtle.ConstantTimeCompare([]byte(formCSRF), []byte(sessionCSRF)) != 1 {
+		http.Error(w, "Invalid CSRF token", http.StatusForbidden)
+		return
+	}
+
+	logger := logging.Logger(r.Context()).Sugar()
+	if r.FormValue("revert") != "" {
+		logger.Warnf("Impersonation reverted: %q", original.Id)
+		session.Values[IdentityValueName] = original
+	} else {
+		id := strings.TrimSpace(r.FormValue("id"))
+		if id == "" {
+			id = original.Id
+		}
+		roles := []string{"authenticated"}
+		for _, role := range strings.Split(r.FormValue("roles"), ",") {
+			role = strings.ToLower(strings.TrimSpace(role))
+			if role != "" && role != "anonymous" && role != "authenticated" {
+				roles = append(roles, role)
+			}
+		}
+		sort.Strings(roles)
+		impersonated := *original
+		impersonated.Id = id
+		impersonated.Name = id
+		impersonated.Email = ""
+		impersonated.Roles = roles
+		impersonated.Impersonator = original
+		logger.Warnf("Impersonation started: %q as %q with roles %v", original.Id, id, roles)
+		session.Values[IdentityValueName] = &impersonated
+	}
+	err := session.Save(r, w)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, sessionReturn, http.StatusFound)
+}
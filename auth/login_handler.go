@@ -2,13 +2,24 @@ package auth
 
 import (
 	"net/http"
+	"net/url"
+	"time"
 
 	"github.com/google/uuid"
+	"golang.org/x/oauth2"
 )
 
+// MFAClaimsRequest is the claims request parameter requiring multi-factor authentication.
+const MFAClaimsRequest = `{"id_token":{"amr":{"essential":true,"values":["mfa"]}}}`
+
 func (a *Auth) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Cache-Control", "no-cache")
 
+	if a.DevAuth && !a.EasyAuth {
+		http.Redirect(w, r, DevLoginHandlerPath+"?"+ReturnValueName+"="+url.QueryEscape(r.FormValue(ReturnValueName)), http.StatusFound)
+		return
+	}
+
 	if !a.EasyAuth && a.OAuth2Config == nil {
 		http.Error(w, "OpenID Connect auth config failed: see log output", http.StatusInternalServerError)
 		return
@@ -24,6 +35,11 @@ func (a *Auth) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	session.Values[StateValueName] = sessionState
 	session.Values[ReturnValueName] = sessionReturn
 	session.Values[DebugValueName] = sessionDebug
+	if r.FormValue(PromptValueName) == "login" {
+		session.Values[StepUpValueName] = time.Now().Unix()
+	} else {
+		delete(session.Values, StepUpValueName)
+	}
 	err := session.Save(r, w)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -35,6 +51,17 @@ func (a *Auth) LoginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	authCodeURL := a.OAuth2Config.AuthCodeURL(sessionState, a.OAuth2AuthCodeOptions...)
+	authCodeOptions := append([]oauth2.AuthCodeOption{}, a.OAuth2AuthCodeOptions...)
+	if r.FormValue(PromptValueName) == "login" {
+		authCodeOptions = append(authCodeOptions, oauth2.SetAuthURLParam(PromptValueName, "login"))
+	}
+	if acrValues := r.FormValue(ACRValuesValueName); acrValues != "" {
+		authCodeOptions = append(authCodeOptions, oauth2.SetAuthURLParam(ACRValuesValueName, acrValues))
+	}
+	if r.FormValue(MFAValueName) == "true" {
+		// prompt=login alone doesn't make Azure AD require MFA: request mfa in the amr claim
+		authCodeOptions = append(authCodeOptions, oauth2.SetAuthURLParam(ClaimsValueName, MFAClaimsRequest))
+	}
+	authCodeURL := a.RequestOAuth2Config(r).AuthCodeURL(sessionState, authCodeOptions...)
 	http.Redirect(w, r, authCodeURL, http.StatusFound)
 }
@@ -1,10 +1,15 @@
 package auth
 
 import (
+	"crypto/sha256"
 	"encoding/gob"
+	"encoding/hex"
 	"net/http"
+	"sort"
+	"strings"
 
 	"github.com/gorilla/sessions"
+	"github.com/yaegashi/pswa/logging"
 )
 
 const (
@@ -13,6 +18,13 @@ const (
 	ReturnValueName   = "return"
 	IdentityValueName = "identity"
 	DebugValueName    = "debug"
+	CSRFValueName     = "csrf"
+	// Step-up re-authentication parameters passed to the identity provider
+	PromptValueName    = "prompt"
+	ACRValuesValueName = "acr_values"
+	MFAValueName       = "mfa"
+	ClaimsValueName    = "claims"
+	StepUpValueName    = "stepup"
 )
 
 type Identity struct {
@@ -21,14 +33,39 @@ type Identity struct {
 	Name  string   `json:"name,omitempty"`
 	Email string   `json:"email,omitempty"`
 	Roles []string `json:"roles,omitempty"`
+	// Claims of the sign-in for authorization policies
+	TenantID string   `json:"tid,omitempty"`
+	AMR      []string `json:"amr,omitempty"`
+	ACR      string   `json:"acr,omitempty"`
+	AuthTime int64    `json:"authTime,omitempty"`
+	// Time of the step-up re-authentication request answered by the sign-in
+	StepUp int64 `json:"stepUp,omitempty"`
+	// Original identity of the administrator impersonating this identity
+	Impersonator *Identity `json:"impersonator,omitempty"`
+}
+
+// HasRole reports whether identity has role.
+func (identity *Identity) HasRole(role string) bool {
+	n := sort.SearchStrings(identity.Roles, role)
+	return n < len(identity.Roles) && identity.Roles[n] == role
 }
 
 func init() {
 	gob.Register(&Identity{})
 }
 
+// SiteSessionName returns the session cookie name of the virtual host.
+// Cookie values are signed with their names, so a session of a host is rejected by other hosts.
+func SiteSessionName(host string) string {
+	if host == "" {
+		return SessionCookieName
+	}
+	sum := sha256.Sum256([]byte(strings.ToLower(host)))
+	return SessionCookieName + "-" + hex.EncodeToString(sum[:8])
+}
+
 func (a *Auth) Session(r *http.Request) *sessions.Session {
-	session, _ := a.SessionStore.Get(r, SessionCookieName)
+	session, _ := a.SessionStore.Get(r, a.SessionName)
 	session.Options.HttpOnly = true
 	session.Options.Secure = true
 	session.Options.SameSite = http.SameSiteNoneMode
@@ -36,6 +73,46 @@ func (a *Auth) Session(r *http.Request) *sessions.Session {
 }
 
 func (a *Auth) Identity(r *http.Request) *Identity {
+	identity := a.sessionOrTokenIdentity(r)
+	if identity == nil {
+		identity = a.LoggedBasicIdentity(r)
+	}
+	if identity == nil {
+		identity = a.CertificateIdentity(r)
+	}
+	return identity
+}
+
+// IdentityWithoutBasic is Identity without HTTP basic auth,
+// whose bcrypt comparison is too costly to run for every request.
+func (a *Auth) IdentityWithoutBasic(r *http.Request) *Identity {
+	identity := a.sessionOrTokenIdentity(r)
+	if identity == nil {
+		identity = a.CertificateIdentity(r)
+	}
+	return identity
+}
+
+// LoggedBasicIdentity is BasicIdentity logging rejected credentials.
+func (a *Auth) LoggedBasicIdentity(r *http.Request) *Identity {
+	identity, err := a.BasicIdentity(r)
+	if err != nil {
+		logging.Logger(r.Context()).Sugar().Warnf("Basic auth rejected: %s", err)
+	}
+	return identity
+}
+
+func (a *Auth) sessionOrTokenIdentity(r *http.Request) *Identity {
 	identity, _ := a.Session(r).Values[IdentityValueName].(*Identity)
+	if identity == nil {
+		identity = a.TokenIdentity(r)
+	}
+	if identity == nil {
+		var err error
+		identity, err = a.AppIdentity(r)
+		if err != nil {
+			logging.Logger(r.Context()).Sugar().Warnf("Bearer token rejected: %s", err)
+		}
+	}
 	return identity
 }
@@ -0,0 +1,38 @@
+package auth
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/yaegashi/pswa/config"
+)
+
+// MultiTenant reports whether tenantID is an authority for users of multiple tenants.
+func MultiTenant(tenantID string) bool {
+	switch strings.ToLower(tenantID) {
+	case "common", "organizations", "consumers":
+		return true
+	}
+	return false
+}
+
+// CheckSignIn applies the signIn settings of the config to identity.
+// It returns an error if identity is not allowed to sign in.
+func (a *Auth) CheckSignIn(identity *Identity, guest bool) error {
+	s := a.Config().SignIn
+	if !s.AllowsTenant(identity.TenantID) {
+		return fmt.Errorf("Tenant %q not allowed to sign in", identity.TenantID)
+	}
+	if !guest || s == nil {
+		return nil
+	}
+	if s.Guests == config.GuestsDeny {
+		return fmt.Errorf("Guest users not allowed to sign in")
+	}
+	if s.GuestRole != "" {
+		identity.Roles = append(identity.Roles, s.GuestRole)
+		sort.Strings(identity.Roles)
+	}
+	return nil
+}
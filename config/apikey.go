@@ -0,0 +1,51 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const DefaultAPIKeyHeader = "X-Api-Key"
+
+// APIKey is a service identity authenticated with the SHA-256 hash of its key.
+type APIKey struct {
+	ID      string    `json:"id,omitempty"`
+	Name    string    `json:"name,omitempty"`
+	Hash    string    `json:"hash,omitempty"`
+	Roles   []string  `json:"roles,omitempty"`
+	Expires string    `json:"expires,omitempty"`
+	Sum     []byte    `json:"-"`
+	Expiry  time.Time `json:"-"`
+}
+
+func (k *APIKey) Compile() error {
+	var ps Problems
+	if k.ID == "" {
+		ps.Add("id", fmt.Errorf("API key ID missing"))
+	}
+	sum, err := hex.DecodeString(strings.TrimPrefix(strings.ToLower(k.Hash), "sha256:"))
+	if err != nil || len(sum) != sha256.Size {
+		ps.Add("hash", fmt.Errorf("API key %q hash must be SHA-256 in hex", k.ID))
+	}
+	k.Sum = sum
+	k.Expiry = time.Time{}
+	if k.Expires != "" {
+		t, err := time.Parse(time.RFC3339, k.Expires)
+		if err != nil {
+			ps.Add("expires", fmt.Errorf("API key %q bad expiry: %w", k.ID, err))
+		}
+		k.Expiry = t
+	}
+	for i, r := range k.Roles {
+		k.Roles[i] = strings.ToLower(r)
+	}
+	return ps.Err()
+}
+
+// Expired reports whether k is expired at t.
+func (k *APIKey) Expired(t time.Time) bool {
+	return !k.Expiry.IsZero() && !t.Before(k.Expiry)
+}
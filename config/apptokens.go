@@ -0,0 +1,6 @@
+package config
+
+// AppTokens enables bearer access tokens of daemon apps from the client credentials grant.
+type AppTokens struct {
+	Audiences []string `json:"audiences,omitempty"`
+}
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const DefaultBasicAuthRealm = "pswa"
+
+// BasicAuth enables HTTP basic authentication with users in the htpasswd format.
+type BasicAuth struct {
+	Realm     string            `json:"realm,omitempty"`
+	Htpasswd  string            `json:"htpasswd,omitempty"`
+	Users     map[string][]byte `json:"-"`
+	DummyHash []byte            `json:"-"`
+}
+
+func (b *BasicAuth) Compile() error {
+	var ps Problems
+	b.Users = map[string][]byte{}
+	s := bufio.NewScanner(strings.NewReader(b.Htpasswd))
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		user, hash, ok := strings.Cut(line, ":")
+		switch {
+		case !ok || user == "":
+			ps.Add("htpasswd", fmt.Errorf("Htpasswd line %d malformed", n))
+		case !strings.HasPrefix(hash, "$2y$") && !strings.HasPrefix(hash, "$2a$") && !strings.HasPrefix(hash, "$2b$"):
+			ps.Add("htpasswd", fmt.Errorf("Htpasswd line %d user %q not bcrypt hash", n, user))
+		default:
+			b.Users[user] = []byte(hash)
+		}
+	}
+	if len(b.Users) == 0 && len(ps) == 0 {
+		ps.Add("htpasswd", fmt.Errorf("Htpasswd has no users"))
+	}
+	cost := bcrypt.MinCost
+	for _, hash := range b.Users {
+		if c, err := bcrypt.Cost(hash); err == nil && c > cost {
+			cost = c
+		}
+	}
+	b.DummyHash, _ = bcrypt.GenerateFromPassword([]byte("pswa"), cost)
+	return ps.Err()
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/gobwas/glob"
+)
+
+// ClientCertificate maps verified client certificates to an identity with roles.
+type ClientCertificate struct {
+	Subject     string    `json:"subject,omitempty"`
+	SAN         string    `json:"san,omitempty"`
+	Fingerprint string    `json:"fingerprint,omitempty"`
+	Name        string    `json:"name,omitempty"`
+	Roles       []string  `json:"roles,omitempty"`
+	SubjectGlob glob.Glob `json:"-"`
+	SANGlob     glob.Glob `json:"-"`
+}
+
+// Fingerprint returns the SHA-256 fingerprint of cert in lowercase hex.
+func Fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *ClientCertificate) Compile() error {
+	var ps Problems
+	if c.Subject == "" && c.SAN == "" && c.Fingerprint == "" {
+		ps.Add("", fmt.Errorf("Client certificate needs at least one of subject, san and fingerprint"))
+	}
+	c.SubjectGlob = nil
+	if c.Subject != "" {
+		g, err := compileGlob(c.Subject)
+		if err != nil {
+			ps.Add("subject", fmt.Errorf("Client certificate bad glob pattern %q: %w", c.Subject, err))
+		}
+		c.SubjectGlob = g
+	}
+	c.SANGlob = nil
+	if c.SAN != "" {
+		g, err := compileGlob(c.SAN)
+		if err != nil {
+			ps.Add("san", fmt.Errorf("Client certificate bad glob pattern %q: %w", c.SAN, err))
+		}
+		c.SANGlob = g
+	}
+	c.Fingerprint = strings.ToLower(strings.ReplaceAll(c.Fingerprint, ":", ""))
+	if c.Fingerprint != "" {
+		b, err := hex.DecodeString(c.Fingerprint)
+		if err != nil || len(b) != sha256.Size {
+			ps.Add("fingerprint", fmt.Errorf("Client certificate fingerprint %q must be SHA-256 in hex", c.Fingerprint))
+		}
+	}
+	for i, r := range c.Roles {
+		c.Roles[i] = strings.ToLower(r)
+	}
+	return ps.Err()
+}
+
+// Match reports whether cert satisfies all of subject, san and fingerprint of c.
+func (c *ClientCertificate) Match(cert *x509.Certificate) bool {
+	if c.Fingerprint != "" && c.Fingerprint != Fingerprint(cert) {
+		return false
+	}
+	if c.SubjectGlob != nil && !c.SubjectGlob.Match(cert.Subject.String()) {
+		return false
+	}
+	if c.SANGlob != nil {
+		sans := append([]string{}, cert.DNSNames...)
+		sans = append(sans, cert.EmailAddresses...)
+		for _, u := range cert.URIs {
+			sans = append(sans, u.String())
+		}
+		for _, ip := range cert.IPAddresses {
+			sans = append(sans, ip.String())
+		}
+		for _, san := range sans {
+			if c.SANGlob.Match(san) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
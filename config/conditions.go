@@ -0,0 +1,81 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/gobwas/glob"
+)
+
+// Conditions restricts a route to requests with query parameters, headers and cookies
+// whose values match the glob patterns.
+type Conditions struct {
+	Query   map[string]string `json:"query,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Cookies map[string]string `json:"cookies,omitempty"`
+	globs   []condition
+}
+
+type condition struct {
+	kind string
+	name string
+	glob glob.Glob
+}
+
+func (c *Conditions) Compile() error {
+	var ps Problems
+	c.globs = nil
+	for _, kind := range []string{"query", "headers", "cookies"} {
+		m := map[string]map[string]string{"query": c.Query, "headers": c.Headers, "cookies": c.Cookies}[kind]
+		names := make([]string, 0, len(m))
+		for name := range m {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			g, err := compileGlob(m[name])
+			if err != nil {
+				ps.Add(joinPath(kind, keyPath(name)), fmt.Errorf("Condition %q bad glob pattern: %w", m[name], err))
+				continue
+			}
+			if kind == "headers" {
+				name = http.CanonicalHeaderKey(name)
+			}
+			c.globs = append(c.globs, condition{kind: kind, name: name, glob: g})
+		}
+	}
+	return ps.Err()
+}
+
+// Match reports whether r satisfies all the conditions, or the reason why not.
+func (c *Conditions) Match(r *http.Request) (bool, string) {
+	var query map[string][]string
+	for _, cond := range c.globs {
+		var values []string
+		switch cond.kind {
+		case "query":
+			if query == nil {
+				query = r.URL.Query()
+			}
+			values = query[cond.name]
+		case "headers":
+			values = r.Header.Values(cond.name)
+		case "cookies":
+			if cookie, err := r.Cookie(cond.name); err == nil {
+				values = []string{cookie.Value}
+			}
+		}
+		ok := false
+		for _, v := range values {
+			if cond.glob.Match(v) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false, fmt.Sprintf("%s %q does not match", cond.kind, cond.name)
+		}
+	}
+	return true, ""
+}
@@ -1,19 +1,31 @@
 package config
 
 import (
-	"encoding/json"
-	"os"
+	"errors"
+	"fmt"
 	"sort"
-
-	"github.com/tidwall/jsonc"
+	"strings"
 )
 
 type Config struct {
-	TestHandler        bool                `json:"testHandler"`
-	TestRoot           bool                `json:"testRoot"`
-	Routes             []*Route            `json:"routes,omitempty"`
-	Roles              []*Role             `json:"roles,omitempty"`
-	NavigationFallback *NavigationFallback `json:"navigationFallback,omitempty"`
+	Schema             string               `json:"$schema,omitempty"`
+	Include            []string             `json:"include,omitempty"`
+	TestHandler        bool                 `json:"testHandler"`
+	TestRoot           bool                 `json:"testRoot"`
+	Routes             []*Route             `json:"routes,omitempty"`
+	Roles              []*Role              `json:"roles,omitempty"`
+	NavigationFallback *NavigationFallback  `json:"navigationFallback,omitempty"`
+	AdminRole          string               `json:"adminRole,omitempty"`
+	MethodFallthrough  bool                 `json:"methodFallthrough,omitempty"`
+	SignIn             *SignIn              `json:"signIn,omitempty"`
+	ClientCertificates []*ClientCertificate `json:"clientCertificates,omitempty"`
+	APIKeys            []*APIKey            `json:"apiKeys,omitempty"`
+	APIKeyHeader       string               `json:"apiKeyHeader,omitempty"`
+	AppTokens          *AppTokens           `json:"appTokens,omitempty"`
+	BasicAuth          *BasicAuth           `json:"basicAuth,omitempty"`
+	Server             *Server              `json:"server,omitempty"`
+	Files              []string             `json:"-"`
+	Secrets            []string             `json:"-"`
 }
 
 func (c *Config) MemberRoles(members []string) []string {
@@ -34,27 +46,122 @@ func (c *Config) MemberRoles(members []string) []string {
 	return roles
 }
 
-func New(configPath string) (*Config, error) {
-	c := &Config{}
-	b, err := os.ReadFile(configPath)
-	if err != nil {
-		return nil, err
+func (c *Config) compile() Problems {
+	var ps Problems
+	compiled := make([]bool, len(c.Routes))
+	for i, r := range c.Routes {
+		path := joinPath("$.routes", indexPath(i))
+		if r == nil {
+			ps.Add(path, errors.New("Route must be an object"))
+			continue
+		}
+		err := r.Compile()
+		if err != nil {
+			ps.Add(path, err)
+			continue
+		}
+		compiled[i] = true
+		n := 0
+		for _, s := range []string{r.Redirect, r.Rewrite, r.Proxy} {
+			if s != "" {
+				n++
+			}
+		}
+		if n > 1 {
+			ps.Warnf(path, "Route %q has more than one of redirect, rewrite and proxy", r.Pattern())
+		}
+		for j := 0; j < i; j++ {
+			if compiled[j] && c.Routes[j].Shadows(r) {
+				ps.Warnf(path, "Route %q unreachable: shadowed by earlier route %q", r.Pattern(), c.Routes[j].Pattern())
+				break
+			}
+		}
 	}
-	err = json.Unmarshal(jsonc.ToJSON(b), &c)
-	if err != nil {
-		return nil, err
+	if c.NavigationFallback != nil {
+		err := c.NavigationFallback.Compile()
+		if err != nil {
+			ps.Add("$.navigationFallback", err)
+		}
 	}
-	for _, r := range c.Routes {
-		err = r.Compile()
+	if c.SignIn != nil {
+		err := c.SignIn.Compile()
 		if err != nil {
-			return nil, err
+			ps.Add("$.signIn", err)
 		}
 	}
-	if c.NavigationFallback != nil {
-		err = c.NavigationFallback.Compile()
+	for i, cc := range c.ClientCertificates {
+		path := joinPath("$.clientCertificates", indexPath(i))
+		if cc == nil {
+			ps.Add(path, errors.New("Client certificate must be an object"))
+			continue
+		}
+		err := cc.Compile()
+		if err != nil {
+			ps.Add(path, err)
+		}
+	}
+	keyMap := map[string]int{}
+	for i, k := range c.APIKeys {
+		path := joinPath("$.apiKeys", indexPath(i))
+		if k == nil {
+			ps.Add(path, errors.New("API key must be an object"))
+			continue
+		}
+		err := k.Compile()
+		if err != nil {
+			ps.Add(path, err)
+			continue
+		}
+		if j, ok := keyMap[k.ID]; ok {
+			ps.Add(joinPath(path, "id"), fmt.Errorf("API key %q already defined in apiKeys[%d]", k.ID, j))
+			continue
+		}
+		keyMap[k.ID] = i
+	}
+	if c.BasicAuth != nil {
+		err := c.BasicAuth.Compile()
 		if err != nil {
-			return nil, err
+			ps.Add("$.basicAuth", err)
+		}
+	}
+	c.AdminRole = strings.ToLower(c.AdminRole)
+	roleMap := map[string]int{}
+	for i, r := range c.Roles {
+		path := joinPath("$.roles", indexPath(i))
+		if r == nil {
+			ps.Add(path, errors.New("Role must be an object"))
+			continue
+		}
+		err := r.Compile()
+		if err != nil {
+			ps.Add(path, err)
+			continue
+		}
+		if len(r.Members) == 0 {
+			ps.Warnf(joinPath(path, "members"), "Role %q has no members", r.Role)
 		}
+		if j, ok := roleMap[r.Role]; ok {
+			ps.Warnf(path, "Role %q already defined in roles[%d]", r.Role, j)
+			continue
+		}
+		roleMap[r.Role] = i
+	}
+	return ps
+}
+
+func Parse(b []byte) (*Config, Problems) {
+	return (&Loader{}).Parse(b)
+}
+
+func Load(configPath string) (*Config, Problems) {
+	return (&Loader{}).Load(configPath)
+}
+
+func New(configPath string) (*Config, error) {
+	c, ps := Load(configPath)
+	err := ps.Err()
+	if err != nil {
+		return nil, err
 	}
 	return c, nil
 }
@@ -0,0 +1,36 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConfigRouteWarnings(t *testing.T) {
+	tests := []struct {
+		name   string
+		routes string
+		want   []string
+	}{
+		{"no warnings", `[{"route": "/api/v1/*"}, {"route": "/api/*"}]`, nil},
+		{"shadowed", `[{"route": "/api/*"}, {"route": "/api/v1/*"}]`, []string{`$.routes[1]: Route "/api/v1/*" unreachable: shadowed by earlier route "/api/*"`}},
+		{"shadowed by method", `[{"route": "/api/*", "methods": ["POST"]}, {"route": "/api/x", "methods": ["POST"]}, {"route": "/api/y"}]`, []string{`$.routes[1]: Route "/api/x" unreachable: shadowed by earlier route "/api/*"`}},
+		{"conditions", `[{"route": "/api/*", "conditions": {"query": {"v": "2"}}}, {"route": "/api/*"}]`, nil},
+		{"redirect and rewrite", `[{"route": "/a", "redirect": "/b", "rewrite": "/c"}]`, []string{`$.routes[0]: Route "/a" has more than one of redirect, rewrite and proxy`}},
+		{"redirect and proxy", `[{"route": "/a/*", "redirect": "/b", "proxy": "http://localhost:3000"}]`, []string{`$.routes[0]: Route "/a/*" has more than one of redirect, rewrite and proxy`}},
+		{"redirect only", `[{"route": "/a", "redirect": "/b"}]`, nil},
+	}
+	for _, tt := range tests {
+		ld := &Loader{}
+		_, ps := ld.Parse([]byte(`{"routes": ` + tt.routes + `}`))
+		if errs := ps.Errors(); len(errs) > 0 {
+			t.Fatalf("%s: Parse failed: %s", tt.name, errs)
+		}
+		var got []string
+		for _, p := range ps.Warnings() {
+			got = append(got, p.Path+": "+p.Message)
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("%s: warnings = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -1,5 +1,7 @@
 package config
 
+import "fmt"
+
 type NavigationFallback struct {
 	Rewrite  string    `json:"rewrite,omitempty"`
 	Exclude  []string  `json:"exclude,omitempty"`
@@ -7,6 +9,10 @@ type NavigationFallback struct {
 }
 
 func (f *NavigationFallback) Compile() error {
+	var ps Problems
+	if len(f.Rewrite) == 0 || f.Rewrite[0] != '/' {
+		ps.Add("rewrite", fmt.Errorf("Navigation fallback rewrite %q non-absolute path", f.Rewrite))
+	}
 	f.Globbers = make([]Globber, len(f.Exclude)+1)
 	err := f.Globbers[0].Compile("/.auth/*")
 	if err != nil {
@@ -15,8 +21,8 @@ func (f *NavigationFallback) Compile() error {
 	for i := 0; i < len(f.Exclude); i++ {
 		err := f.Globbers[i+1].Compile(f.Exclude[i])
 		if err != nil {
-			return err
+			ps.Add(joinPath("exclude", indexPath(i)), err)
 		}
 	}
-	return nil
+	return ps.Err()
 }
@@ -0,0 +1,95 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+var unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+func jsonName(f reflect.StructField) string {
+	if !f.IsExported() {
+		return ""
+	}
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		name = f.Name
+	}
+	return name
+}
+
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if name := jsonName(f); name != "" {
+			fields[name] = f.Type
+		}
+	}
+	return fields
+}
+
+// checkFields reports object keys in v that are not fields of t.
+func checkFields(v any, t reflect.Type, path string, ps *Problems, lenient bool) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if reflect.PointerTo(t).Implements(unmarshalerType) {
+		return
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		m, ok := v.(map[string]any)
+		if !ok {
+			return
+		}
+		fields := jsonFields(t)
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			kpath := joinPath(path, keyPath(k))
+			ft, ok := fields[k]
+			if ok {
+				checkFields(m[k], ft, kpath, ps, lenient)
+				continue
+			}
+			msg := fmt.Sprintf("Unknown field %q", k)
+			for name := range fields {
+				if strings.EqualFold(name, k) || strings.EqualFold(name, k+"s") || strings.EqualFold(name+"s", k) {
+					msg += fmt.Sprintf(", did you mean %q?", name)
+					break
+				}
+			}
+			if lenient {
+				ps.Warnf(kpath, "%s", msg)
+			} else {
+				*ps = append(*ps, &Problem{Path: kpath, Message: msg})
+			}
+		}
+	case reflect.Slice:
+		a, ok := v.([]any)
+		if !ok {
+			return
+		}
+		for i, e := range a {
+			checkFields(e, t.Elem(), joinPath(path, indexPath(i)), ps, lenient)
+		}
+	case reflect.Map:
+		m, ok := v.(map[string]any)
+		if !ok {
+			return
+		}
+		for k, e := range m {
+			checkFields(e, t.Elem(), joinPath(path, keyPath(k)), ps, lenient)
+		}
+	}
+}
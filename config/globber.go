@@ -7,24 +7,172 @@ import (
 	"github.com/gobwas/glob"
 )
 
+// Globber matches paths with a glob pattern.
+// If wildcards appear only in the last path segment, the pattern is split at the last '/'
+// into a literal prefix and a leaf glob in which '*' also matches '/'.
+// Otherwise, e.g. "/docs/**/*.pdf" or "/*/private/*", the part after the literal prefix
+// is matched segment by segment, where '*' does not match '/' and a "**" segment
+// matches zero or more segments.
 type Globber struct {
-	Prefix string
-	Leaf   string
-	Glob   glob.Glob
+	Prefix   string
+	Leaf     string
+	Glob     glob.Glob
+	Segments []glob.Glob
+}
+
+const globMeta = `*?[]{}\`
+
+// maxGlobAlternatives limits the brace expansion of a glob pattern.
+const maxGlobAlternatives = 256
+
+// compileGlob compiles a glob pattern, rejecting unbalanced braces and empty alternatives.
+// Braces are expanded here into alternatives without braces,
+// because gobwas/glob panics or fails to match on some patterns with braces like "{0*,0}".
+func compileGlob(pattern string, separators ...rune) (glob.Glob, error) {
+	depth, class, empty := 0, false, false
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case c == '\\':
+			i++
+		case class:
+			class = c != ']'
+		case c == '[':
+			class = true
+		case c == '{':
+			depth++
+			empty = true
+			continue
+		case c == '}' && depth == 0:
+			return nil, fmt.Errorf("unbalanced braces")
+		case c == ',' && depth > 0, c == '}':
+			if empty {
+				return nil, fmt.Errorf("empty alternative in braces")
+			}
+			if c == '}' {
+				depth--
+			} else {
+				empty = true
+				continue
+			}
+		}
+		empty = false
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("unbalanced braces")
+	}
+	var alts []string
+	err := expandBraces(pattern, &alts)
+	if err != nil {
+		return nil, err
+	}
+	var globs anyGlob
+	for _, alt := range alts {
+		g, err := glob.Compile(alt, separators...)
+		if err != nil {
+			return nil, err
+		}
+		globs = append(globs, g)
+	}
+	if len(globs) == 1 {
+		return globs[0], nil
+	}
+	return globs, nil
+}
+
+// expandBraces appends the alternatives of a balanced pattern with braces expanded to alts.
+func expandBraces(pattern string, alts *[]string) error {
+	open, start, depth, class := -1, -1, 0, false
+	var parts []string
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case c == '\\':
+			i++
+		case class:
+			class = c != ']'
+		case c == '[':
+			class = true
+		case c == '{':
+			if depth == 0 {
+				open, start = i, i
+			}
+			depth++
+		case c == ',' && depth == 1:
+			parts = append(parts, pattern[start+1:i])
+			start = i
+		case c == '}':
+			depth--
+			if depth > 0 {
+				continue
+			}
+			parts = append(parts, pattern[start+1:i])
+			for _, part := range parts {
+				err := expandBraces(pattern[:open]+part+pattern[i+1:], alts)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+	if len(*alts) >= maxGlobAlternatives {
+		return fmt.Errorf("too many alternatives in braces")
+	}
+	*alts = append(*alts, pattern)
+	return nil
+}
+
+// anyGlob matches if any of the globs matches.
+type anyGlob []glob.Glob
+
+func (gs anyGlob) Match(s string) bool {
+	for _, g := range gs {
+		if g.Match(s) {
+			return true
+		}
+	}
+	return false
 }
 
 func (gl *Globber) Compile(route string) error {
 	if len(route) == 0 || route[0] != '/' {
 		return fmt.Errorf("Route %q non-absolute path", route)
 	}
+	meta := strings.IndexAny(route, globMeta)
+	if meta >= 0 && (strings.Contains(route[meta:], "/") || strings.Contains(route, "**")) {
+		n := strings.LastIndexByte(route[:meta], '/')
+		segments := []glob.Glob{}
+		for _, seg := range strings.Split(route[n+1:], "/") {
+			if seg == "**" {
+				segments = append(segments, nil)
+				continue
+			}
+			if strings.Contains(seg, "**") {
+				return fmt.Errorf("Route %q bad glob pattern: ** must be a whole path segment", route)
+			}
+			// Match segments with the leading '/' as the leaf globs
+			g, err := compileGlob("/" + seg)
+			if err != nil {
+				return fmt.Errorf("Route %q bad glob pattern: %w", route, err)
+			}
+			segments = append(segments, g)
+		}
+		gl.Prefix = route[:n]
+		gl.Leaf = route[n:]
+		gl.Glob = nil
+		gl.Segments = segments
+		return nil
+	}
 	n := strings.LastIndexByte(route, '/')
-	g, err := glob.Compile(route[n:])
+	g, err := compileGlob(route[n:])
 	if err != nil {
 		return fmt.Errorf("Route %q bad glob pattern: %w", route, err)
 	}
 	gl.Prefix = route[:n]
 	gl.Leaf = route[n:]
 	gl.Glob = g
+	gl.Segments = nil
 	return nil
 }
 
@@ -37,8 +185,68 @@ func (gl *Globber) Match(path string) bool {
 		return false
 	}
 	n := len(gl.Prefix)
+	if gl.Segments != nil {
+		var segs []string
+		if len(path) > n {
+			if path[n] != '/' {
+				return false
+			}
+			for rest := path[n:]; rest != ""; {
+				i := strings.IndexByte(rest[1:], '/') + 1
+				if i == 0 {
+					i = len(rest)
+				}
+				segs = append(segs, rest[:i])
+				rest = rest[i:]
+			}
+		}
+		return matchSegments(gl.Segments, segs)
+	}
 	if gl.Leaf == "/*" {
 		return len(path) == n || path[n] == '/'
 	}
 	return gl.Glob.Match(path[n:])
 }
+
+// matchSegments reports whether globs match segs, where a nil glob matches zero or more segments.
+// It runs in O(len(globs) * len(segs)) time: reach[j] is whether the globs so far match segs[:j].
+func matchSegments(globs []glob.Glob, segs []string) bool {
+	reach := make([]bool, len(segs)+1)
+	next := make([]bool, len(segs)+1)
+	reach[0] = true
+	for _, g := range globs {
+		if g == nil {
+			seen := false
+			for j := range reach {
+				seen = seen || reach[j]
+				next[j] = seen
+			}
+		} else {
+			next[0] = false
+			for j, seg := range segs {
+				next[j+1] = reach[j] && g.Match(seg)
+			}
+		}
+		reach, next = next, reach
+	}
+	return reach[len(segs)]
+}
+
+func (gl *Globber) literal() bool {
+	return !strings.ContainsAny(gl.Leaf, globMeta)
+}
+
+// Covers reports whether gl matches every path that o matches.
+// It is conservative and may return false for some covering patterns.
+func (gl *Globber) Covers(o *Globber) bool {
+	if gl.Prefix == o.Prefix && gl.Leaf == o.Leaf {
+		return true
+	}
+	if (gl.Leaf == "/*" || gl.Leaf == "/**") && (o.Prefix == gl.Prefix || strings.HasPrefix(o.Prefix, gl.Prefix+"/")) {
+		return true
+	}
+	if o.literal() {
+		return gl.Match(o.Prefix + o.Leaf)
+	}
+	return false
+}
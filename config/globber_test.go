@@ -0,0 +1,227 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGlobberCompile(t *testing.T) {
+	tests := []struct {
+		route    string
+		prefix   string
+		leaf     string
+		segments bool
+		err      bool
+	}{
+		{route: "/", prefix: "", leaf: "/"},
+		{route: "/index.html", prefix: "", leaf: "/index.html"},
+		{route: "/admin/*", prefix: "/admin", leaf: "/*"},
+		{route: "/*.{js,css}", prefix: "", leaf: "/*.{js,css}"},
+		{route: "/docs/**", prefix: "/docs", leaf: "/**", segments: true},
+		{route: "/docs/**/*.pdf", prefix: "/docs", leaf: "/**/*.pdf", segments: true},
+		{route: "/*/private/*", prefix: "", leaf: "/*/private/*", segments: true},
+		{route: "/a/b*/c", prefix: "/a", leaf: "/b*/c", segments: true},
+		{route: "admin", err: true},
+		{route: "", err: true},
+		{route: "/docs/a**/b", err: true},
+		{route: "/[", err: true},
+		{route: "/{0{", err: true},
+		{route: "/a}", err: true},
+		{route: "/@{}", err: true},
+		{route: "/secret/@{,}", err: true},
+		{route: "/s/a{,}b", err: true},
+		{route: "/{a,}", err: true},
+		{route: "/{,a}/x", err: true},
+		{route: "/{a,{}}", err: true},
+		{route: "/{a,b}", prefix: "", leaf: "/{a,b}"},
+		{route: `/\{,\}`, prefix: "", leaf: `/\{,\}`},
+		{route: "/*.{js,css}/x", prefix: "", leaf: "/*.{js,css}/x", segments: true},
+	}
+	for _, tt := range tests {
+		var gl Globber
+		err := gl.Compile(tt.route)
+		if tt.err {
+			if err == nil {
+				t.Errorf("Compile(%q) succeeded, want error", tt.route)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Compile(%q) failed: %s", tt.route, err)
+			continue
+		}
+		if gl.Prefix != tt.prefix || gl.Leaf != tt.leaf {
+			t.Errorf("Compile(%q) = prefix %q leaf %q, want %q %q", tt.route, gl.Prefix, gl.Leaf, tt.prefix, tt.leaf)
+		}
+		if (gl.Segments != nil) != tt.segments {
+			t.Errorf("Compile(%q) segment mode = %v, want %v", tt.route, gl.Segments != nil, tt.segments)
+		}
+	}
+}
+
+func TestGlobberMatch(t *testing.T) {
+	tests := []struct {
+		route string
+		path  string
+		match bool
+	}{
+		// Literal routes
+		{"/index.html", "/index.html", true},
+		{"/index.html", "/index.htm", false},
+		{"/index.html", "/a/index.html", false},
+		// Legacy leaf globs: '*' also matches '/'
+		{"/admin/*", "/admin", true},
+		{"/admin/*", "/admin/", true},
+		{"/admin/*", "/admin/a/b/c", true},
+		{"/admin/*", "/administrator", false},
+		{"/*.{js,css}", "/app.js", true},
+		{"/*.{js,css}", "/lib/app.css", true},
+		{"/*.{js,css}", "/app.json", false},
+		{"/img/*.png", "/img/a/b.png", true},
+		{"/img/?.png", "/img/a.png", true},
+		{"/img/?.png", "/img/ab.png", false},
+		// "**" matches zero or more segments
+		{"/docs/**", "/docs", true},
+		{"/docs/**", "/docs/a/b", true},
+		{"/docs/**", "/docsx", false},
+		{"/docs/**/*.pdf", "/docs/a.pdf", true},
+		{"/docs/**/*.pdf", "/docs/a/b/c.pdf", true},
+		{"/docs/**/*.pdf", "/docs/a/b/c.txt", false},
+		{"/**/z", "/z", true},
+		{"/**/z", "/a/b/z", true},
+		{"/**/a/**/z", "/a/z", true},
+		{"/**/a/**/z", "/b/z", false},
+		// Mid-path '*' does not match '/'
+		{"/*/private/*", "/u/private/x", true},
+		{"/*/private/*", "/u/v/private/x", false},
+		{"/*/private/*", "/u/private/x/y", false},
+		{"/a/b*/c", "/a/bbb/c", true},
+		{"/a/b*/c", "/a/b/x/c", false},
+		{"/a/?/c", "/a//c", false},
+		{"/a/*/c", "/a//c", true},
+		// Braces are expanded into alternatives
+		{"/{0*,0}", "/0", true},
+		{"/{0*,0}", "/01", true},
+		{"/{0*,0}", "/1", false},
+		{"/{a,b{c,d}}.x", "/bd.x", true},
+		{"/{a,b{c,d}}.x", "/b.x", false},
+		{"/*/{a,b}", "/u/b", true},
+		{"/*/{a,b}", "/u/c", false},
+		{`/\{a\}`, "/{a}", true},
+		{"/[{]x", "/{x", true},
+	}
+	for _, tt := range tests {
+		var gl Globber
+		err := gl.Compile(tt.route)
+		if err != nil {
+			t.Fatalf("Compile(%q) failed: %s", tt.route, err)
+		}
+		if got := gl.Match(tt.path); got != tt.match {
+			t.Errorf("%q.Match(%q) = %v, want %v", tt.route, tt.path, got, tt.match)
+		}
+	}
+}
+
+func TestGlobberMatchLongPath(t *testing.T) {
+	var gl Globber
+	err := gl.Compile("/**/a/**/a/**/a/**/z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gl.Match(strings.Repeat("/a", 10000)) {
+		t.Errorf("Match succeeded without z")
+	}
+	if !gl.Match(strings.Repeat("/a", 10000) + "/z") {
+		t.Errorf("Match failed")
+	}
+}
+
+func TestGlobberStripPrefix(t *testing.T) {
+	tests := []struct {
+		route string
+		path  string
+		want  string
+	}{
+		{"/api/*", "/api/v1/users", "/v1/users"},
+		{"/api/*", "/api", ""},
+		{"/*", "/a/b", "/a/b"},
+		{"/docs/**/*.pdf", "/docs/a/b.pdf", "/a/b.pdf"},
+		{"/index.html", "/index.html", "/index.html"},
+	}
+	for _, tt := range tests {
+		var gl Globber
+		err := gl.Compile(tt.route)
+		if err != nil {
+			t.Fatalf("Compile(%q) failed: %s", tt.route, err)
+		}
+		if got := gl.StripPrefix(tt.path); got != tt.want {
+			t.Errorf("%q.StripPrefix(%q) = %q, want %q", tt.route, tt.path, got, tt.want)
+		}
+	}
+}
+
+// FuzzGlobberMatch checks the legacy leaf glob against the segment matcher
+// for patterns and paths within a single last segment, where both must agree.
+func FuzzGlobberMatch(f *testing.F) {
+	f.Add("*", "a")
+	f.Add("*.{js,css}", "app.js")
+	f.Add("?.png", "ab.png")
+	f.Add("[a-c]x", "bx")
+	f.Add("index.html", "index.html")
+	f.Add("*", "")
+	f.Fuzz(func(t *testing.T, leaf, seg string) {
+		if strings.Contains(leaf, "/") || strings.Contains(leaf, "**") || strings.Contains(seg, "/") {
+			t.Skip()
+		}
+		var legacy, segment Globber
+		if legacy.Compile("/base/"+leaf) != nil || legacy.Segments != nil {
+			t.Skip()
+		}
+		// A leading "**" forces the segment matcher and matches zero segments here
+		if segment.Compile("/base/**/"+leaf) != nil || segment.Segments == nil {
+			t.Skip()
+		}
+		path := "/base/" + seg
+		if got, want := legacy.Match(path), segment.Match(path); got != want {
+			t.Errorf("pattern %q path %q: legacy %v, segment %v", leaf, path, got, want)
+		}
+	})
+}
+
+func TestGlobberCovers(t *testing.T) {
+	tests := []struct {
+		route  string
+		other  string
+		covers bool
+	}{
+		{"/admin/*", "/admin/*", true},
+		{"/admin/*", "/admin/users", true},
+		{"/admin/*", "/admin/users/*", true},
+		{"/admin/*", "/admin", true},
+		{"/admin/*", "/administrator", false},
+		{"/admin/*", "/public/*", false},
+		{"/*", "/docs/**/*.pdf", true},
+		{"/docs/**", "/docs/a/b", true},
+		{"/docs/**", "/docs/**/*.pdf", true},
+		{"/docs/**/*.pdf", "/docs/a/b.pdf", true},
+		{"/docs/**/*.pdf", "/docs/a/b.txt", false},
+		{"/docs/**/*.pdf", "/docs/*", false},
+		{"/*.html", "/index.html", true},
+		{"/*.html", "/*.htm", false},
+		{"/index.html", "/*.html", false},
+	}
+	for _, tt := range tests {
+		var gl, o Globber
+		err := gl.Compile(tt.route)
+		if err != nil {
+			t.Fatalf("Compile(%q) failed: %s", tt.route, err)
+		}
+		err = o.Compile(tt.other)
+		if err != nil {
+			t.Fatalf("Compile(%q) failed: %s", tt.other, err)
+		}
+		if got := gl.Covers(&o); got != tt.covers {
+			t.Errorf("%q.Covers(%q) = %v, want %v", tt.route, tt.other, got, tt.covers)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/gobwas/glob"
+)
+
+// Host is a virtual host served with its own web root, config and auth settings.
+// Empty settings are inherited from the default host.
+type Host struct {
+	Host         string    `json:"host"`
+	WWWRoot      string    `json:"wwwRoot"`
+	Config       string    `json:"config,omitempty"`
+	TestRoot     string    `json:"testRoot,omitempty"`
+	TenantID     string    `json:"tenantId,omitempty"`
+	ClientID     string    `json:"clientId,omitempty"`
+	ClientSecret string    `json:"clientSecret,omitempty"`
+	RedirectURI  string    `json:"redirectUri,omitempty"`
+	AuthParams   string    `json:"authParams,omitempty"`
+	Glob         glob.Glob `json:"-"`
+}
+
+type Hosts struct {
+	Schema string  `json:"$schema,omitempty"`
+	Hosts  []*Host `json:"hosts"`
+}
+
+func (h *Host) Compile() error {
+	var ps Problems
+	if h.Host == "" {
+		ps.Add("host", errors.New("Host name missing"))
+	} else {
+		g, err := compileGlob(strings.ToLower(h.Host), '.')
+		if err != nil {
+			ps.Add("host", fmt.Errorf("Host %q bad glob pattern: %w", h.Host, err))
+		}
+		h.Glob = g
+	}
+	if h.WWWRoot == "" {
+		ps.Add("wwwRoot", fmt.Errorf("Host %q web root missing", h.Host))
+	}
+	return ps.Err()
+}
+
+// Match reports whether the lower-case host name matches h.
+func (h *Host) Match(host string) bool {
+	return h.Glob != nil && h.Glob.Match(host)
+}
+
+// LoadHosts reads and compiles the hosts file at hostsPath.
+func (ld *Loader) LoadHosts(hostsPath string) (*Hosts, Problems) {
+	var ps Problems
+	b, err := os.ReadFile(hostsPath)
+	if err != nil {
+		return nil, Problems{{Message: err.Error()}}
+	}
+	doc := ld.parseDocument(b, hostsPath, reflect.TypeOf(Hosts{}), &ps)
+	if doc == nil || ps.Err() != nil {
+		return nil, ps
+	}
+	b, _ = json.Marshal(doc.value)
+	hs := &Hosts{}
+	err = json.Unmarshal(b, hs)
+	if err != nil {
+		return nil, append(ps, &Problem{File: hostsPath, Message: err.Error()})
+	}
+	for i, h := range hs.Hosts {
+		path := joinPath("$.hosts", indexPath(i))
+		if h == nil {
+			ps.Add(path, errors.New("Host must be an object"))
+			continue
+		}
+		err := h.Compile()
+		if err != nil {
+			ps.Add(path, err)
+		}
+	}
+	for _, p := range ps {
+		if p.File == "" {
+			p.File = hostsPath
+			p.Line, p.Column = doc.locations.locate(p.Path)
+		}
+	}
+	return hs, ps
+}
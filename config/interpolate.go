@@ -0,0 +1,130 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const FileRefPrefix = "@file:"
+
+// interpolate expands ${VAR} and ${VAR:-default} in s,
+// or reads the file relative to doc if s is "@file:<path>".
+// "$${" is an escape for a literal "${".
+func (ld *Loader) interpolate(s string, doc *document) (string, error) {
+	if strings.HasPrefix(s, FileRefPrefix) {
+		p := s[len(FileRefPrefix):]
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(filepath.Dir(doc.file), p)
+		}
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return "", err
+		}
+		doc.refs = append(doc.refs, p)
+		return strings.TrimRight(string(b), "\r\n"), nil
+	}
+	lookupEnv := ld.LookupEnv
+	if lookupEnv == nil {
+		lookupEnv = os.LookupEnv
+	}
+	var b strings.Builder
+	for {
+		n := strings.Index(s, "${")
+		if n < 0 {
+			b.WriteString(s)
+			return b.String(), nil
+		}
+		if n > 0 && s[n-1] == '$' {
+			b.WriteString(s[:n])
+			b.WriteString("{")
+			s = s[n+2:]
+			continue
+		}
+		b.WriteString(s[:n])
+		m := strings.IndexByte(s[n:], '}')
+		if m < 0 {
+			return "", fmt.Errorf("Unterminated variable reference in %q", s)
+		}
+		ref := s[n+2 : n+m]
+		s = s[n+m+1:]
+		name, def, hasDef := strings.Cut(ref, ":-")
+		if name == "" {
+			return "", fmt.Errorf("Empty variable reference")
+		}
+		val, ok := lookupEnv(name)
+		if hasDef && val == "" {
+			val = def
+		} else if !ok {
+			return "", fmt.Errorf("Undefined variable %q", name)
+		}
+		b.WriteString(val)
+	}
+}
+
+// interpolateAll replaces all string values in v in place.
+func (ld *Loader) interpolateAll(v any, doc *document, path string, ps *Problems) any {
+	switch v := v.(type) {
+	case string:
+		s, err := ld.interpolate(v, doc)
+		if err != nil {
+			ps.Add(path, err)
+			return v
+		}
+		if s != strings.ReplaceAll(v, "$${", "${") || strings.HasPrefix(v, FileRefPrefix) {
+			doc.secrets = append(doc.secrets, s)
+		}
+		return s
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			v[k] = ld.interpolateAll(v[k], doc, joinPath(path, keyPath(k)), ps)
+		}
+	case []any:
+		for i, e := range v {
+			v[i] = ld.interpolateAll(e, doc, joinPath(path, indexPath(i)), ps)
+		}
+	}
+	return v
+}
+
+// Redacted returns c as a JSON value for display
+// with the interpolated strings, which may be secrets, replaced by "[redacted]".
+func (c *Config) Redacted() any {
+	b, _ := json.Marshal(c)
+	var v any
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	dec.Decode(&v)
+	secrets := map[string]struct{}{}
+	for _, s := range c.Secrets {
+		secrets[s] = struct{}{}
+	}
+	return redact(v, secrets)
+}
+
+func redact(v any, secrets map[string]struct{}) any {
+	switch v := v.(type) {
+	case string:
+		if _, ok := secrets[v]; ok {
+			return "[redacted]"
+		}
+	case map[string]any:
+		for k, e := range v {
+			v[k] = redact(e, secrets)
+		}
+	case []any:
+		for i, e := range v {
+			v[i] = redact(e, secrets)
+		}
+	}
+	return v
+}
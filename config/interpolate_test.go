@@ -0,0 +1,85 @@
+package config
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoaderInterpolate(t *testing.T) {
+	env := map[string]string{"HOST": "example.com", "EMPTY": ""}
+	lookupEnv := func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+	tests := []struct {
+		value string
+		want  string
+		err   string
+	}{
+		{value: "plain", want: "plain"},
+		{value: "https://${HOST}/x", want: "https://example.com/x"},
+		{value: "${HOST}${HOST}", want: "example.comexample.com"},
+		{value: "${UNDEFINED:-fallback}", want: "fallback"},
+		{value: "${EMPTY:-fallback}", want: "fallback"},
+		{value: "${HOST:-fallback}", want: "example.com"},
+		{value: "${EMPTY}", want: ""},
+		{value: "$${HOST}", want: "${HOST}"},
+		{value: "$$1 $HOST", want: "$$1 $HOST"},
+		{value: "${UNDEFINED}", err: `Undefined variable "UNDEFINED"`},
+		{value: "${HOST", err: "Unterminated variable reference"},
+		{value: "${}", err: "Empty variable reference"},
+		{value: "@file:nonexistent", err: "nonexistent"},
+	}
+	for _, tt := range tests {
+		b, _ := json.Marshal(map[string]any{"routes": []any{map[string]any{"route": "/", "headers": map[string]string{"X": tt.value}}}})
+		ld := &Loader{LookupEnv: lookupEnv}
+		c, ps := ld.Parse(b)
+		if tt.err != "" {
+			if err := ps.Err(); err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%q: error = %v, want %q", tt.value, err, tt.err)
+			}
+			continue
+		}
+		if err := ps.Err(); err != nil {
+			t.Errorf("%q: Parse failed: %s", tt.value, err)
+			continue
+		}
+		if got := c.Routes[0].Headers["X"]; got != tt.want {
+			t.Errorf("%q: value = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestLoaderFileRef(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"site/pswa.config.json": `{"include": ["inc/*.json"], "basicAuth": {"htpasswd": "@file:../secrets/htpasswd"}}`,
+		"site/inc/a.json":       `{"routes": [{"route": "/a", "headers": {"X-Key": "@file:key.txt"}}]}`,
+		"site/inc/key.txt":      "s3cret\r\n\n",
+		"secrets/htpasswd":      "alice:$2y$05$9xqPVeBvc.nM6Kxk.M1Jl.yW9R5qxfQRbnW7pPIcpVCZ4xlBZ/6Ya\n",
+	})
+	c, ps := (&Loader{}).Load(filepath.Join(dir, "site/pswa.config.json"))
+	if len(ps) > 0 {
+		t.Fatalf("Load problems: %s", ps)
+	}
+	if got := c.Routes[0].Headers["X-Key"]; got != "s3cret" {
+		t.Errorf("header = %q, want %q", got, "s3cret")
+	}
+	if _, ok := c.BasicAuth.Users["alice"]; !ok {
+		t.Errorf("htpasswd not read relative to the config file")
+	}
+	files := map[string]bool{}
+	for _, f := range c.Files {
+		files[filepath.Base(f)] = true
+	}
+	for _, f := range []string{"pswa.config.json", "a.json", "key.txt", "htpasswd"} {
+		if !files[f] {
+			t.Errorf("Files %q missing %q", c.Files, f)
+		}
+	}
+	b, _ := json.Marshal(c.Redacted())
+	if strings.Contains(string(b), "s3cret") || strings.Contains(string(b), "alice") {
+		t.Errorf("Redacted() = %s, want secrets redacted", b)
+	}
+}
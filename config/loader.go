@@ -0,0 +1,318 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/jsonc"
+)
+
+type Loader struct {
+	// Lenient reports unknown fields as warnings instead of errors.
+	Lenient bool
+	// LookupEnv looks up variables for interpolation.  Default: os.LookupEnv
+	LookupEnv func(string) (string, bool)
+	// Environment selects the overlay file, e.g. "prod" for pswa.config.prod.json.
+	Environment string
+}
+
+// document is a decoded and interpolated config file before merging.
+type document struct {
+	file      string
+	refs      []string
+	secrets   []string
+	locations *locations
+	value     map[string]any
+	failed    bool
+}
+
+// origin is the location of a merged value in its document.
+type origin struct {
+	doc  *document
+	path string
+}
+
+func (ld *Loader) parseDocument(b []byte, file string, t reflect.Type, ps *Problems) *document {
+	var dps Problems
+	b = jsonc.ToJSON(b)
+	doc := &document{file: file, locations: newLocations(b)}
+	defer func() {
+		for _, p := range dps {
+			p.File = file
+			if p.Line == 0 {
+				p.Line, p.Column = doc.locations.locate(p.Path)
+			}
+		}
+		*ps = append(*ps, dps...)
+	}()
+
+	var v any
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	err := dec.Decode(&v)
+	if err != nil {
+		p := &Problem{Message: err.Error()}
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			p.Line, p.Column = doc.locations.position(int(syntaxErr.Offset))
+		} else {
+			p.Line, p.Column = doc.locations.position(len(b))
+		}
+		dps = append(dps, p)
+		return nil
+	}
+	m, ok := v.(map[string]any)
+	if !ok {
+		dps.Add("$", errors.New("Config must be an object"))
+		return nil
+	}
+	checkFields(m, t, "$", &dps, ld.Lenient)
+	doc.value = ld.interpolateAll(m, doc, "$", &dps).(map[string]any)
+	return doc
+}
+
+func (ld *Loader) loadDocuments(file string, visited map[string]bool, ps *Problems) []*document {
+	abs, err := filepath.Abs(file)
+	if err == nil {
+		if visited[abs] {
+			*ps = append(*ps, &Problem{File: file, Message: "Config file included more than once"})
+			return nil
+		}
+		visited[abs] = true
+	}
+	b, err := os.ReadFile(file)
+	if err != nil {
+		*ps = append(*ps, &Problem{Message: err.Error()})
+		return []*document{{file: file, failed: true}}
+	}
+	doc := ld.parseDocument(b, file, reflect.TypeOf(Config{}), ps)
+	if doc == nil {
+		return []*document{{file: file, failed: true}}
+	}
+	return append([]*document{doc}, ld.includeDocuments(doc, visited, ps)...)
+}
+
+func (ld *Loader) includeDocuments(doc *document, visited map[string]bool, ps *Problems) []*document {
+	var docs []*document
+	include, _ := doc.value["include"].([]any)
+	for i, v := range include {
+		path := joinPath("$.include", indexPath(i))
+		line, column := doc.locations.locate(path)
+		pattern, _ := v.(string)
+		if doc.file != "" && !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(doc.file), pattern)
+		}
+		files, err := filepath.Glob(pattern)
+		if err != nil {
+			*ps = append(*ps, &Problem{File: doc.file, Path: path, Line: line, Column: column, Message: err.Error()})
+			continue
+		}
+		if len(files) == 0 {
+			*ps = append(*ps, &Problem{File: doc.file, Path: path, Line: line, Column: column, Message: fmt.Sprintf("Include %q matches no files", pattern), Warning: true})
+			continue
+		}
+		sort.Strings(files)
+		for _, file := range files {
+			docs = append(docs, ld.loadDocuments(file, visited, ps)...)
+		}
+	}
+	return docs
+}
+
+// merge combines docs in the order of precedence.
+// Routes are concatenated, roles are merged by name with the union of members,
+// and other values are taken from the first document that has them.
+func merge(docs []*document, primary *document) (map[string]any, map[string]origin) {
+	merged := map[string]any{}
+	origins := map[string]origin{"$": {doc: primary, path: "$"}}
+	var routes, roles []any
+	roleIndex := map[string]int{}
+	for _, doc := range docs {
+		keys := make([]string, 0, len(doc.value))
+		for k := range doc.value {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			v := doc.value[k]
+			path := joinPath("$", keyPath(k))
+			a, isArray := v.([]any)
+			switch {
+			case k == "include":
+			case k == "routes" && isArray:
+				for j, r := range a {
+					origins[joinPath(path, indexPath(len(routes)))] = origin{doc: doc, path: joinPath(path, indexPath(j))}
+					routes = append(routes, r)
+				}
+			case k == "roles" && isArray:
+				for j, r := range a {
+					m, _ := r.(map[string]any)
+					name, _ := m["role"].(string)
+					if i, ok := roleIndex[strings.ToLower(name)]; ok && name != "" {
+						roles[i] = mergeRole(roles[i].(map[string]any), m)
+						continue
+					}
+					if name != "" {
+						roleIndex[strings.ToLower(name)] = len(roles)
+					}
+					origins[joinPath(path, indexPath(len(roles)))] = origin{doc: doc, path: joinPath(path, indexPath(j))}
+					roles = append(roles, r)
+				}
+			default:
+				if _, ok := merged[k]; !ok {
+					merged[k] = v
+					origins[path] = origin{doc: doc, path: path}
+				}
+			}
+		}
+	}
+	if routes != nil {
+		merged["routes"] = routes
+	}
+	if roles != nil {
+		merged["roles"] = roles
+	}
+	return merged, origins
+}
+
+func mergeRole(a, b map[string]any) map[string]any {
+	m := map[string]any{}
+	for k, v := range a {
+		m[k] = v
+	}
+	am, _ := a["members"].([]any)
+	bm, _ := b["members"].([]any)
+	seen := map[any]bool{}
+	var members []any
+	for _, v := range append(append([]any{}, am...), bm...) {
+		if !seen[v] {
+			seen[v] = true
+			members = append(members, v)
+		}
+	}
+	m["members"] = members
+	return m
+}
+
+// relocate maps the path of p in the merged config to its original document.
+func relocate(p *Problem, origins map[string]origin) {
+	for path := p.Path; ; {
+		if o, ok := origins[path]; ok {
+			p.File = o.doc.file
+			p.Path = o.path + p.Path[len(path):]
+			p.Line, p.Column = o.doc.locations.locate(p.Path)
+			return
+		}
+		var ok bool
+		path, ok = parentPath(path)
+		if !ok {
+			return
+		}
+	}
+}
+
+// build merges and compiles docs.  Problems of the documents except decoding failures
+// don't prevent compiling, so that all problems are reported at once.
+func (ld *Loader) build(docs []*document, primary *document, ps Problems) (*Config, Problems) {
+	for _, doc := range docs {
+		if doc.failed {
+			return nil, ps
+		}
+	}
+	if primary == nil {
+		return nil, ps
+	}
+	merged, origins := merge(docs, primary)
+	b, _ := json.Marshal(merged)
+	c := &Config{}
+	err := json.Unmarshal(b, c)
+	if err != nil {
+		p := &Problem{Message: err.Error()}
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			p.Path = "$"
+			for _, f := range strings.Split(typeErr.Field, ".") {
+				if _, err := strconv.Atoi(f); err == nil {
+					p.Path = joinPath(p.Path, "["+f+"]")
+				} else {
+					p.Path = joinPath(p.Path, keyPath(f))
+				}
+			}
+		}
+		relocate(p, origins)
+		return nil, append(ps, p)
+	}
+	for _, p := range c.compile() {
+		relocate(p, origins)
+		if !reported(ps, p) {
+			ps = append(ps, p)
+		}
+	}
+	for _, doc := range docs {
+		if doc.file != "" {
+			c.Files = append(c.Files, doc.file)
+		}
+		c.Files = append(c.Files, doc.refs...)
+		c.Secrets = append(c.Secrets, doc.secrets...)
+	}
+	if ps.Err() != nil {
+		return nil, ps
+	}
+	return c, ps
+}
+
+// reported reports whether an error at the same value or its parent as p is in ps,
+// e.g. an undefined variable of the proxy URL which fails to compile too.
+func reported(ps Problems, p *Problem) bool {
+	for _, e := range ps {
+		if !e.Warning && e.File == p.File && (p.Path == e.Path || strings.HasPrefix(p.Path, e.Path+".") || strings.HasPrefix(p.Path, e.Path+"[")) {
+			return true
+		}
+	}
+	return false
+}
+
+// Parse decodes and compiles JSONC config data.
+// Problems without Err() are warnings that do not prevent using the config.
+func (ld *Loader) Parse(b []byte) (*Config, Problems) {
+	var ps Problems
+	doc := ld.parseDocument(b, "", reflect.TypeOf(Config{}), &ps)
+	if doc == nil {
+		return nil, ps
+	}
+	docs := append([]*document{doc}, ld.includeDocuments(doc, map[string]bool{}, &ps)...)
+	return ld.build(docs, doc, ps)
+}
+
+// OverlayPath returns the path of the overlay file of configPath for env.
+func OverlayPath(configPath, env string) string {
+	ext := filepath.Ext(configPath)
+	return strings.TrimSuffix(configPath, ext) + "." + env + ext
+}
+
+// Load reads and parses the config file at configPath with its includes,
+// and merges the environment overlay over it if it exists.
+func (ld *Loader) Load(configPath string) (*Config, Problems) {
+	var ps Problems
+	visited := map[string]bool{}
+	docs := ld.loadDocuments(configPath, visited, &ps)
+	var primary *document
+	if len(docs) > 0 {
+		primary = docs[0]
+	}
+	if ld.Environment != "" {
+		overlayPath := OverlayPath(configPath, ld.Environment)
+		if _, err := os.Stat(overlayPath); err == nil {
+			docs = append(ld.loadDocuments(overlayPath, visited, &ps), docs...)
+		}
+	}
+	return ld.build(docs, primary, ps)
+}
@@ -0,0 +1,164 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		err := os.MkdirAll(filepath.Dir(p), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(p, []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func routePatterns(c *Config) []string {
+	var patterns []string
+	for _, r := range c.Routes {
+		patterns = append(patterns, r.Pattern())
+	}
+	return patterns
+}
+
+func TestLoaderMerge(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"pswa.config.json": `{
+			// Includes are merged in the pattern and file name order
+			"include": ["inc/*.json", "extra.json"],
+			"adminRole": "base",
+			"methodFallthrough": true,
+			"routes": [{"route": "/base"}],
+			"roles": [{"role": "admin", "members": ["a", "b"]}]
+		}`,
+		"pswa.config.prod.json": `{
+			"adminRole": "overlay",
+			"routes": [{"route": "/overlay"}],
+			"roles": [{"role": "Admin", "members": ["c"]}]
+		}`,
+		"inc/b.json": `{"routes": [{"route": "/b"}], "roles": [{"role": "reader", "members": ["r"]}]}`,
+		"inc/a.json": `{"adminRole": "a", "routes": [{"route": "/a"}], "roles": [{"role": "admin", "members": ["b", "d"]}]}`,
+		"extra.json": `{"routes": [{"route": "/extra"}], "navigationFallback": {"rewrite": "/index.html"}}`,
+	})
+	tests := []struct {
+		env       string
+		routes    []string
+		adminRole string
+		roles     map[string][]string
+	}{
+		{
+			env:       "",
+			routes:    []string{"/base", "/a", "/b", "/extra"},
+			adminRole: "base",
+			roles:     map[string][]string{"admin": {"a", "b", "d"}, "reader": {"r"}},
+		},
+		{
+			env:       "prod",
+			routes:    []string{"/overlay", "/base", "/a", "/b", "/extra"},
+			adminRole: "overlay",
+			// Roles are merged by case-insensitive names, and the first one has precedence
+			roles: map[string][]string{"Admin": {"c", "a", "b", "d"}, "reader": {"r"}},
+		},
+		{
+			env:       "missing",
+			routes:    []string{"/base", "/a", "/b", "/extra"},
+			adminRole: "base",
+			roles:     map[string][]string{"admin": {"a", "b", "d"}, "reader": {"r"}},
+		},
+	}
+	for _, tt := range tests {
+		ld := &Loader{Environment: tt.env}
+		c, ps := ld.Load(filepath.Join(dir, "pswa.config.json"))
+		if len(ps) > 0 {
+			t.Fatalf("env %q: Load problems: %s", tt.env, ps)
+		}
+		if got := routePatterns(c); !reflect.DeepEqual(got, tt.routes) {
+			t.Errorf("env %q: routes = %q, want %q", tt.env, got, tt.routes)
+		}
+		if c.AdminRole != tt.adminRole {
+			t.Errorf("env %q: adminRole = %q, want %q", tt.env, c.AdminRole, tt.adminRole)
+		}
+		if !c.MethodFallthrough || c.NavigationFallback == nil || c.NavigationFallback.Rewrite != "/index.html" {
+			t.Errorf("env %q: values only in one file not merged", tt.env)
+		}
+		roles := map[string][]string{}
+		for _, r := range c.Roles {
+			roles[r.Role] = r.Members
+		}
+		if !reflect.DeepEqual(roles, tt.roles) {
+			t.Errorf("env %q: roles = %v, want %v", tt.env, roles, tt.roles)
+		}
+	}
+}
+
+func TestLoaderIncludeProblems(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  []string
+	}{
+		{
+			name: "cycle",
+			files: map[string]string{
+				"pswa.config.json": `{"include": ["a.json"]}`,
+				"a.json":           `{"include": ["pswa.config.json"]}`,
+			},
+			want: []string{"error: Config file included more than once"},
+		},
+		{
+			name: "no match",
+			files: map[string]string{
+				"pswa.config.json": `{"include": ["none/*.json"]}`,
+			},
+			want: []string{"warning: $.include[0]: Include"},
+		},
+		{
+			name: "problems of included file",
+			files: map[string]string{
+				"pswa.config.json": `{"include": ["a.json"], "routes": [{"route": "bad"}]}`,
+				"a.json":           `{"routes": [{"route": "/a", "allowedRole": ["x"]}, {"routeRegex": "("}]}`,
+			},
+			want: []string{
+				`a.json:1:44: error: $.routes[0].allowedRole: Unknown field "allowedRole"`,
+				`pswa.config.json:1:46: error: $.routes[0].route: Route "bad" non-absolute path`,
+				`a.json:1:67: error: $.routes[1].routeRegex: Route "(" bad regular expression`,
+			},
+		},
+		{
+			name: "syntax error of included file",
+			files: map[string]string{
+				"pswa.config.json": `{"include": ["a.json"], "routes": [{"route": "bad"}]}`,
+				"a.json":           `{"routes": [`,
+			},
+			want: []string{"a.json:1:13: error: unexpected EOF"},
+		},
+	}
+	for _, tt := range tests {
+		dir := writeFiles(t, tt.files)
+		c, ps := (&Loader{}).Load(filepath.Join(dir, "pswa.config.json"))
+		if ps.Err() != nil && c != nil {
+			t.Errorf("%s: config returned with errors", tt.name)
+		}
+		if len(ps) != len(tt.want) {
+			t.Errorf("%s: problems = %q, want %q", tt.name, ps, tt.want)
+			continue
+		}
+		for i, p := range ps {
+			if !strings.Contains(p.String(), tt.want[i]) {
+				t.Errorf("%s: problem %d = %q, want %q", tt.name, i, p, tt.want[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+)
+
+var identRegexp = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+func keyPath(key string) string {
+	if identRegexp.MatchString(key) {
+		return key
+	}
+	b, _ := json.Marshal(key)
+	return "[" + string(b) + "]"
+}
+
+func indexPath(i int) string {
+	return fmt.Sprintf("[%d]", i)
+}
+
+// locations maps JSON paths of all values in b to their byte offsets.
+type locations struct {
+	b       []byte
+	offsets map[string]int
+}
+
+func newLocations(b []byte) *locations {
+	l := &locations{b: b, offsets: map[string]int{}}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	l.scan(dec, "$")
+	return l
+}
+
+func (l *locations) skip(off int) int {
+	for off < len(l.b) {
+		switch l.b[off] {
+		case ' ', '\t', '\r', '\n', ':', ',':
+			off++
+		default:
+			return off
+		}
+	}
+	return off
+}
+
+func (l *locations) scan(dec *json.Decoder, path string) bool {
+	l.offsets[path] = l.skip(int(dec.InputOffset()))
+	tok, err := dec.Token()
+	if err != nil {
+		return false
+	}
+	switch tok {
+	case json.Delim('{'):
+		for dec.More() {
+			tok, err := dec.Token()
+			if err != nil {
+				return false
+			}
+			key, _ := tok.(string)
+			if !l.scan(dec, joinPath(path, keyPath(key))) {
+				return false
+			}
+		}
+		_, err = dec.Token()
+	case json.Delim('['):
+		for i := 0; dec.More(); i++ {
+			if !l.scan(dec, joinPath(path, indexPath(i))) {
+				return false
+			}
+		}
+		_, err = dec.Token()
+	}
+	return err == nil
+}
+
+func (l *locations) position(off int) (line, column int) {
+	if off > len(l.b) {
+		off = len(l.b)
+	}
+	line = 1 + bytes.Count(l.b[:off], []byte("\n"))
+	column = 1 + off - (bytes.LastIndexByte(l.b[:off], '\n') + 1)
+	return line, column
+}
+
+// parentPath returns the path of the parent of the value at path.
+func parentPath(path string) (string, bool) {
+	n := len(path) - 1
+	for n > 0 && path[n] != '.' && path[n] != '[' {
+		n--
+	}
+	if n <= 0 {
+		return "", false
+	}
+	return path[:n], true
+}
+
+// locate finds the position of path, or of its nearest ancestor.
+func (l *locations) locate(path string) (line, column int) {
+	for {
+		if off, ok := l.offsets[path]; ok {
+			return l.position(off)
+		}
+		var ok bool
+		path, ok = parentPath(path)
+		if !ok {
+			return 0, 0
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Policy restricts a route to identities with claims of the sign-in.
+type Policy struct {
+	MFA        bool          `json:"mfa,omitempty"`
+	ACR        string        `json:"acr,omitempty"`
+	Tenants    []string      `json:"tenants,omitempty"`
+	MaxAuthAge string        `json:"maxAuthAge,omitempty"`
+	MaxAge     time.Duration `json:"-"`
+}
+
+func (p *Policy) Compile() error {
+	var ps Problems
+	for i, t := range p.Tenants {
+		p.Tenants[i] = strings.ToLower(t)
+	}
+	p.MaxAge = 0
+	if p.MaxAuthAge != "" {
+		d, err := time.ParseDuration(p.MaxAuthAge)
+		if err != nil {
+			ps.Add("maxAuthAge", fmt.Errorf("Policy bad duration %q: %w", p.MaxAuthAge, err))
+		} else if d <= 0 {
+			ps.Add("maxAuthAge", fmt.Errorf("Policy duration %q must be positive", p.MaxAuthAge))
+		}
+		p.MaxAge = d
+	}
+	return ps.Err()
+}
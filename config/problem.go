@@ -0,0 +1,106 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Problem struct {
+	File    string
+	Path    string
+	Line    int
+	Column  int
+	Message string
+	Warning bool
+}
+
+func (p *Problem) String() string {
+	var b strings.Builder
+	if p.File != "" {
+		b.WriteString(p.File)
+		if p.Line > 0 {
+			fmt.Fprintf(&b, ":%d:%d", p.Line, p.Column)
+		}
+		b.WriteString(": ")
+	}
+	if p.Warning {
+		b.WriteString("warning: ")
+	} else {
+		b.WriteString("error: ")
+	}
+	if p.Path != "" {
+		b.WriteString(p.Path)
+		b.WriteString(": ")
+	}
+	b.WriteString(p.Message)
+	return b.String()
+}
+
+type Problems []*Problem
+
+func (ps *Problems) Add(path string, err error) {
+	var sub Problems
+	if e, ok := err.(Problems); ok {
+		sub = e
+	} else {
+		sub = Problems{{Message: err.Error()}}
+	}
+	for _, p := range sub {
+		q := *p
+		q.Path = joinPath(path, p.Path)
+		*ps = append(*ps, &q)
+	}
+}
+
+func (ps *Problems) Warnf(path string, format string, args ...any) {
+	*ps = append(*ps, &Problem{Path: path, Message: fmt.Sprintf(format, args...), Warning: true})
+}
+
+func (ps Problems) Errors() Problems {
+	var errs Problems
+	for _, p := range ps {
+		if !p.Warning {
+			errs = append(errs, p)
+		}
+	}
+	return errs
+}
+
+func (ps Problems) Warnings() Problems {
+	var warns Problems
+	for _, p := range ps {
+		if p.Warning {
+			warns = append(warns, p)
+		}
+	}
+	return warns
+}
+
+func (ps Problems) Err() error {
+	errs := ps.Errors()
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+func (ps Problems) Error() string {
+	s := make([]string, len(ps))
+	for i, p := range ps {
+		s[i] = p.String()
+	}
+	return strings.Join(s, "\n")
+}
+
+func joinPath(parent, child string) string {
+	switch {
+	case child == "":
+		return parent
+	case parent == "":
+		return child
+	case child[0] == '[':
+		return parent + child
+	default:
+		return parent + "." + child
+	}
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RoleRequirement is a role expression combined with allOf and anyOf.
+type RoleRequirement struct {
+	Role  string             `json:"role,omitempty"`
+	AllOf []*RoleRequirement `json:"allOf,omitempty"`
+	AnyOf []*RoleRequirement `json:"anyOf,omitempty"`
+}
+
+func (q *RoleRequirement) Compile() error {
+	var ps Problems
+	n := 0
+	if q.Role != "" {
+		n++
+	}
+	if q.AllOf != nil {
+		n++
+	}
+	if q.AnyOf != nil {
+		n++
+	}
+	if n != 1 {
+		ps.Add("", fmt.Errorf("Role requirement must have exactly one of role, allOf and anyOf"))
+		return ps.Err()
+	}
+	q.Role = strings.ToLower(q.Role)
+	if q.Role == "anonymous" {
+		ps.Add("role", fmt.Errorf("Role %q cannot be required", q.Role))
+	}
+	compileRequirements("allOf", q.AllOf, &ps)
+	compileRequirements("anyOf", q.AnyOf, &ps)
+	return ps.Err()
+}
+
+func compileRequirements(field string, qs []*RoleRequirement, ps *Problems) {
+	for i, q := range qs {
+		path := joinPath(field, indexPath(i))
+		if q == nil {
+			ps.Add(path, fmt.Errorf("Role requirement must be an object"))
+			continue
+		}
+		err := q.Compile()
+		if err != nil {
+			ps.Add(path, err)
+		}
+	}
+}
+
+// Match reports whether the roles tested by has satisfy q.
+func (q *RoleRequirement) Match(has func(string) bool) bool {
+	switch {
+	case q.Role != "":
+		return has(q.Role)
+	case q.AllOf != nil:
+		for _, e := range q.AllOf {
+			if !e.Match(has) {
+				return false
+			}
+		}
+		return true
+	default:
+		for _, e := range q.AnyOf {
+			if e.Match(has) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func (q *RoleRequirement) String() string {
+	if q.Role != "" {
+		return q.Role
+	}
+	op, qs := " and ", q.AllOf
+	if q.AllOf == nil {
+		op, qs = " or ", q.AnyOf
+	}
+	s := make([]string, len(qs))
+	for i, e := range qs {
+		s[i] = e.String()
+	}
+	return "(" + strings.Join(s, op) + ")"
+}
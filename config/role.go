@@ -1,6 +1,22 @@
 package config
 
+import (
+	"fmt"
+	"strings"
+)
+
 type Role struct {
 	Role    string   `json:"role,omitempty"`
 	Members []string `json:"members,omitempty"`
 }
+
+func (r *Role) Compile() error {
+	var ps Problems
+	switch strings.ToLower(r.Role) {
+	case "":
+		ps.Add("role", fmt.Errorf("Role name missing"))
+	case "anonymous", "authenticated":
+		ps.Add("role", fmt.Errorf("Role %q is built-in", r.Role))
+	}
+	return ps.Err()
+}
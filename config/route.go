@@ -5,33 +5,121 @@ import (
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"regexp"
+	"strconv"
 	"strings"
 )
 
 type Route struct {
-	Route        string            `json:"route,omitempty"`
-	Rewrite      string            `json:"rewrite,omitempty"`
-	Redirect     string            `json:"redirect,omitempty"`
-	Proxy        string            `json:"proxy,omitempty"`
-	AllowedRoles []string          `json:"allowedRoles,omitempty"`
-	Headers      map[string]string `json:"headers,omitempty"`
-	StatusCode   string            `json:"statusCode,omitempty"`
-	Methods      []string          `json:"methods,omitempty"`
-	ProxyHandler http.Handler      `json:"-"`
-	Globber      Globber           `json:"-"`
+	Route           string            `json:"route,omitempty"`
+	RouteRegex      string            `json:"routeRegex,omitempty"`
+	Rewrite         string            `json:"rewrite,omitempty"`
+	Redirect        string            `json:"redirect,omitempty"`
+	Proxy           string            `json:"proxy,omitempty"`
+	AllowedRoles    []string          `json:"allowedRoles,omitempty"`
+	RequiredRoles   []string          `json:"requiredRoles,omitempty"`
+	RoleRequirement *RoleRequirement  `json:"roleRequirement,omitempty"`
+	Policy          *Policy           `json:"policy,omitempty"`
+	DeniedRoles     []string          `json:"deniedRoles,omitempty"`
+	Deny            bool              `json:"deny,omitempty"`
+	AllowAPIKey     bool              `json:"allowApiKey,omitempty"`
+	Headers         map[string]string `json:"headers,omitempty"`
+	StatusCode      string            `json:"statusCode,omitempty"`
+	Methods         []string          `json:"methods,omitempty"`
+	Conditions      *Conditions       `json:"conditions,omitempty"`
+	ProxyHandler    http.Handler      `json:"-"`
+	ProxyExpand     bool              `json:"-"`
+	Globber         Globber           `json:"-"`
+	Regexp          *regexp.Regexp    `json:"-"`
+}
+
+// Protected reports whether r has any authorization rule.
+func (r *Route) Protected() bool {
+	return r.AllowedRoles != nil || r.RequiredRoles != nil || r.RoleRequirement != nil || r.Policy != nil || r.DeniedRoles != nil || r.Deny
+}
+
+// DenyOnly reports whether r only forbids requests without granting roles or any action.
+func (r *Route) DenyOnly() bool {
+	if !r.Deny && r.DeniedRoles == nil {
+		return false
+	}
+	return r.AllowedRoles == nil && r.RequiredRoles == nil && r.RoleRequirement == nil && r.Policy == nil &&
+		r.Redirect == "" && r.Rewrite == "" && r.Proxy == ""
+}
+
+// captureRegexp matches "$$" and capture references like $1 and $name in rewrite, redirect and proxy templates.
+// There's no ${name} form, which is for the interpolation of environment variables.
+var captureRegexp = regexp.MustCompile(`\$(?:\$|([0-9]+)|([A-Za-z_][A-Za-z0-9_]*))`)
+
+func (r *Route) Pattern() string {
+	if r.RouteRegex != "" {
+		return r.RouteRegex
+	}
+	return r.Route
+}
+
+func (r *Route) compileTemplate(field, template string, ps *Problems) bool {
+	refs := captureRegexp.FindAllStringSubmatch(template, -1)
+	if r.Regexp == nil || len(refs) == 0 {
+		return false
+	}
+	names := map[string]bool{}
+	for _, name := range r.Regexp.SubexpNames() {
+		if name != "" {
+			names[name] = true
+		}
+	}
+	for _, ref := range refs {
+		name := ref[1] + ref[2]
+		if name == "" {
+			continue
+		}
+		if n, err := strconv.Atoi(name); err == nil {
+			if n > r.Regexp.NumSubexp() {
+				ps.Add(field, fmt.Errorf("Route %q has no capture group %d", r.Pattern(), n))
+			}
+		} else if !names[name] {
+			ps.Add(field, fmt.Errorf("Route %q has no capture group named %q", r.Pattern(), name))
+		}
+	}
+	return true
 }
 
 func (r *Route) Compile() error {
-	err := r.Globber.Compile(r.Route)
-	if err != nil {
-		return err
+	var ps Problems
+	switch {
+	case r.Route != "" && r.RouteRegex != "":
+		ps.Add("routeRegex", fmt.Errorf("Route %q has both route and routeRegex", r.Route))
+	case r.RouteRegex != "":
+		re, err := regexp.Compile("^(?:" + r.RouteRegex + ")$")
+		if err != nil {
+			ps.Add("routeRegex", fmt.Errorf("Route %q bad regular expression: %w", r.RouteRegex, err))
+		}
+		r.Regexp = re
+	default:
+		err := r.Globber.Compile(r.Route)
+		if err != nil {
+			ps.Add("route", err)
+		}
 	}
+	if r.Conditions != nil {
+		err := r.Conditions.Compile()
+		if err != nil {
+			ps.Add("conditions", err)
+		}
+	}
+	r.compileTemplate("rewrite", r.Rewrite, &ps)
+	r.compileTemplate("redirect", r.Redirect, &ps)
+	r.ProxyExpand = r.compileTemplate("proxy", r.Proxy, &ps)
 	if r.Proxy != "" {
 		u, err := url.Parse(r.Proxy)
 		if err != nil {
-			return fmt.Errorf("Route %q bad proxy URL: %w", r.Route, err)
+			ps.Add("proxy", fmt.Errorf("Route %q bad proxy URL: %w", r.Pattern(), err))
+		} else if r.ProxyExpand {
+			r.ProxyHandler = &httputil.ReverseProxy{Director: func(*http.Request) {}}
+		} else {
+			r.ProxyHandler = httputil.NewSingleHostReverseProxy(u)
 		}
-		r.ProxyHandler = httputil.NewSingleHostReverseProxy(u)
 	}
 	for i, ar := range r.AllowedRoles {
 		if ar == "anonymous" {
@@ -44,5 +132,125 @@ func (r *Route) Compile() error {
 		}
 		r.AllowedRoles[i] = strings.ToLower(ar)
 	}
-	return nil
+	for i, rr := range r.RequiredRoles {
+		r.RequiredRoles[i] = strings.ToLower(rr)
+		if r.RequiredRoles[i] == "anonymous" {
+			ps.Add(joinPath("requiredRoles", indexPath(i)), fmt.Errorf("Role %q cannot be required", rr))
+		}
+	}
+	if r.RoleRequirement != nil {
+		err := r.RoleRequirement.Compile()
+		if err != nil {
+			ps.Add("roleRequirement", err)
+		}
+	}
+	if r.Policy != nil {
+		err := r.Policy.Compile()
+		if err != nil {
+			ps.Add("policy", err)
+		}
+	}
+	for i, dr := range r.DeniedRoles {
+		r.DeniedRoles[i] = strings.ToLower(dr)
+	}
+	return ps.Err()
+}
+
+// AllowsMethod reports whether r matches requests with method.
+// GET implies HEAD.
+func (r *Route) AllowsMethod(method string) bool {
+	if r.Methods == nil {
+		return true
+	}
+	for _, m := range r.Methods {
+		if strings.EqualFold(m, method) || (method == http.MethodHead && strings.EqualFold(m, http.MethodGet)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Route) Match(path string) bool {
+	if r.Regexp != nil {
+		return r.Regexp.MatchString(path)
+	}
+	return r.Globber.Match(path)
+}
+
+// escapePath escapes s for a URL path keeping '/'.
+func escapePath(s string) string {
+	parts := strings.Split(s, "/")
+	for i, p := range parts {
+		parts[i] = url.PathEscape(p)
+	}
+	return strings.Join(parts, "/")
+}
+
+// Expand replaces capture references in template with the submatches of path.
+// If escape is true, the template is a URL and captures are escaped for the path,
+// or for the query after '?' or '#', so that they cannot inject URL components.
+func (r *Route) Expand(template, path string, escape bool) string {
+	if r.Regexp == nil {
+		return template
+	}
+	m := r.Regexp.FindStringSubmatch(path)
+	if m == nil {
+		return template
+	}
+	var b strings.Builder
+	last, query := 0, false
+	for _, loc := range captureRegexp.FindAllStringSubmatchIndex(template, -1) {
+		lit := template[last:loc[0]]
+		b.WriteString(lit)
+		query = query || strings.ContainsAny(lit, "?#")
+		last = loc[1]
+		if loc[2] < 0 && loc[4] < 0 {
+			b.WriteByte('$')
+			continue
+		}
+		var name string
+		if loc[2] >= 0 {
+			name = template[loc[2]:loc[3]]
+		} else {
+			name = template[loc[4]:loc[5]]
+		}
+		var value string
+		if n, err := strconv.Atoi(name); err == nil {
+			if n < len(m) {
+				value = m[n]
+			}
+		} else if i := r.Regexp.SubexpIndex(name); i >= 0 {
+			value = m[i]
+		}
+		switch {
+		case !escape:
+		case query:
+			value = url.QueryEscape(value)
+		default:
+			value = escapePath(value)
+		}
+		b.WriteString(value)
+	}
+	b.WriteString(template[last:])
+	return b.String()
+}
+
+// Shadows reports whether r matches every request that o matches,
+// which makes o unreachable when r comes first.
+func (r *Route) Shadows(o *Route) bool {
+	if r.Conditions != nil {
+		return false
+	}
+	for _, m := range o.Methods {
+		if !r.AllowsMethod(m) {
+			return false
+		}
+	}
+	if r.Methods != nil && o.Methods == nil {
+		return false
+	}
+	if r.Regexp != nil || o.Regexp != nil {
+		return r.RouteRegex == o.RouteRegex
+	}
+	return r.Globber.Covers(&o.Globber)
 }
@@ -0,0 +1,71 @@
+package config
+
+import "testing"
+
+func TestRouteExpand(t *testing.T) {
+	tests := []struct {
+		regex    string
+		template string
+		path     string
+		escape   bool
+		want     string
+	}{
+		{"/blog/(?P<year>[0-9]{4})/([^/]+)", "/posts/$year-$2.html", "/blog/2023/hello", false, "/posts/2023-hello.html"},
+		{"/p/(.*)", "/x/$1y", "/p/a", false, "/x/ay"},
+		{"/p/(?P<a>.*)", "/x/$a-$a_b", "/p/q", false, "/x/q-"},
+		{"/p/(.*)", "/x/$$1", "/p/a", false, "/x/$1"},
+		{"/p/(.*)", "/x/$9", "/p/a", false, "/x/"},
+		{"/p/(.*)", "/x/$1", "/p/a?b", false, "/x/a?b"},
+		{"/p/(.*)", "http://svc/$1", "/p/a?b=1", true, "http://svc/a%3Fb=1"},
+		{"/p/(.*)", "http://svc/$1", "/p/a#f", true, "http://svc/a%23f"},
+		{"/p/(.*)", "http://svc/$1", "/p/a/b c", true, "http://svc/a/b%20c"},
+		{"/p/(.*)", "http://svc/s?q=$1", "/p/a&b=1", true, "http://svc/s?q=a%26b%3D1"},
+	}
+	for _, tt := range tests {
+		r := &Route{RouteRegex: tt.regex}
+		err := r.Compile()
+		if err != nil {
+			t.Fatalf("Compile(%q) failed: %s", tt.regex, err)
+		}
+		if got := r.Expand(tt.template, tt.path, tt.escape); got != tt.want {
+			t.Errorf("%q.Expand(%q, %q, %v) = %q, want %q", tt.regex, tt.template, tt.path, tt.escape, got, tt.want)
+		}
+	}
+}
+
+func TestRouteShadows(t *testing.T) {
+	query := &Conditions{Query: map[string]string{"v": "2"}}
+	tests := []struct {
+		name    string
+		route   *Route
+		other   *Route
+		shadows bool
+	}{
+		{"same glob", &Route{Route: "/api/*"}, &Route{Route: "/api/*"}, true},
+		{"broader glob", &Route{Route: "/api/*"}, &Route{Route: "/api/v1/*"}, true},
+		{"narrower glob", &Route{Route: "/api/v1/*"}, &Route{Route: "/api/*"}, false},
+		{"disjoint globs", &Route{Route: "/api/*"}, &Route{Route: "/docs/*"}, false},
+		{"all methods", &Route{Route: "/api/*"}, &Route{Route: "/api/x", Methods: []string{"POST"}}, true},
+		{"same methods", &Route{Route: "/api/*", Methods: []string{"GET", "POST"}}, &Route{Route: "/api/x", Methods: []string{"post"}}, true},
+		{"GET covers HEAD", &Route{Route: "/api/*", Methods: []string{"GET"}}, &Route{Route: "/api/x", Methods: []string{"HEAD"}}, true},
+		{"other methods", &Route{Route: "/api/*", Methods: []string{"GET"}}, &Route{Route: "/api/x", Methods: []string{"POST"}}, false},
+		{"methods against all", &Route{Route: "/api/*", Methods: []string{"GET"}}, &Route{Route: "/api/x"}, false},
+		{"conditions", &Route{Route: "/api/*", Conditions: query}, &Route{Route: "/api/x"}, false},
+		{"conditions of other", &Route{Route: "/api/*"}, &Route{Route: "/api/x", Conditions: query}, true},
+		{"same regex", &Route{RouteRegex: "^/a/(.*)$"}, &Route{RouteRegex: "^/a/(.*)$"}, true},
+		{"other regex", &Route{RouteRegex: "^/a/(.*)$"}, &Route{RouteRegex: "^/a/b/(.*)$"}, false},
+		{"glob against regex", &Route{Route: "/*"}, &Route{RouteRegex: "^/a/(.*)$"}, false},
+		{"regex against glob", &Route{RouteRegex: "^/(.*)$"}, &Route{Route: "/a"}, false},
+	}
+	for _, tt := range tests {
+		for _, r := range []*Route{tt.route, tt.other} {
+			err := r.Compile()
+			if err != nil {
+				t.Fatalf("%s: Compile failed: %s", tt.name, err)
+			}
+		}
+		if got := tt.route.Shadows(tt.other); got != tt.shadows {
+			t.Errorf("%s: Shadows = %v, want %v", tt.name, got, tt.shadows)
+		}
+	}
+}
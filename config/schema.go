@@ -0,0 +1,76 @@
+package config
+
+import "reflect"
+
+// Schema returns the JSON Schema of the config file format.
+func Schema() map[string]any {
+	defs := map[string]any{}
+	s := typeSchema(reflect.TypeOf(Config{}), defs)
+	s["$schema"] = "http://json-schema.org/draft-07/schema#"
+	s["title"] = "pswa.config.json"
+	if len(defs) > 0 {
+		s["definitions"] = defs
+	}
+	return s
+}
+
+// typeSchema returns the schema of t.
+// Recursive struct types are stored in defs and referred to by $ref.
+func typeSchema(t reflect.Type, defs map[string]any) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int64, reflect.Int32:
+		return map[string]any{"type": "integer"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": typeSchema(t.Elem(), defs)}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": typeSchema(t.Elem(), defs)}
+	case reflect.Struct:
+		ref := map[string]any{"$ref": "#/definitions/" + t.Name()}
+		if _, ok := defs[t.Name()]; ok {
+			return ref
+		}
+		if recursive(t, t, map[reflect.Type]bool{}) {
+			defs[t.Name()] = map[string]any{}
+			defs[t.Name()] = structSchema(t, defs)
+			return ref
+		}
+		return structSchema(t, defs)
+	}
+	return map[string]any{}
+}
+
+func structSchema(t reflect.Type, defs map[string]any) map[string]any {
+	props := map[string]any{}
+	for name, ft := range jsonFields(t) {
+		props[name] = typeSchema(ft, defs)
+	}
+	return map[string]any{"type": "object", "properties": props, "additionalProperties": false}
+}
+
+// recursive reports whether t refers to target through its fields.
+func recursive(t, target reflect.Type, seen map[reflect.Type]bool) bool {
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Map {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || seen[t] {
+		return false
+	}
+	seen[t] = true
+	for _, ft := range jsonFields(t) {
+		e := ft
+		for e.Kind() == reflect.Pointer || e.Kind() == reflect.Slice || e.Kind() == reflect.Map {
+			e = e.Elem()
+		}
+		if e == target || recursive(e, target, seen) {
+			return true
+		}
+	}
+	return false
+}
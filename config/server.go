@@ -0,0 +1,14 @@
+package config
+
+type Server struct {
+	Listen       string `json:"listen,omitempty"`
+	TenantID     string `json:"tenantId,omitempty"`
+	ClientID     string `json:"clientId,omitempty"`
+	RedirectURI  string `json:"redirectUri,omitempty"`
+	AuthParams   string `json:"authParams,omitempty"`
+	TestRootPath string `json:"testRootPath,omitempty"`
+	ConfigWatch  string `json:"configWatch,omitempty"`
+	TLSCert      string `json:"tlsCert,omitempty"`
+	TLSKey       string `json:"tlsKey,omitempty"`
+	TLSClientCA  string `json:"tlsClientCA,omitempty"`
+}
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	GuestsAllow = "allow"
+	GuestsDeny  = "deny"
+)
+
+// SignIn restricts tenants and guest users allowed to sign in.
+type SignIn struct {
+	Tenants   []string `json:"tenants,omitempty"`
+	Guests    string   `json:"guests,omitempty"`
+	GuestRole string   `json:"guestRole,omitempty"`
+}
+
+func (s *SignIn) Compile() error {
+	var ps Problems
+	for i, t := range s.Tenants {
+		s.Tenants[i] = strings.ToLower(t)
+	}
+	s.Guests = strings.ToLower(s.Guests)
+	switch s.Guests {
+	case "", GuestsAllow, GuestsDeny:
+	default:
+		ps.Add("guests", fmt.Errorf("Guests %q must be %q or %q", s.Guests, GuestsAllow, GuestsDeny))
+	}
+	s.GuestRole = strings.ToLower(s.GuestRole)
+	switch s.GuestRole {
+	case "anonymous", "authenticated":
+		ps.Add("guestRole", fmt.Errorf("Role %q is built-in", s.GuestRole))
+	}
+	return ps.Err()
+}
+
+// AllowsTenant reports whether users of tenantID can sign in.
+func (s *SignIn) AllowsTenant(tenantID string) bool {
+	if s == nil || s.Tenants == nil {
+		return true
+	}
+	tenantID = strings.ToLower(tenantID)
+	for _, t := range s.Tenants {
+		if t == tenantID {
+			return true
+		}
+	}
+	return false
+}
@@ -1,26 +1,31 @@
 package core
 
 import (
+	"sync/atomic"
+
 	"github.com/yaegashi/pswa/auth"
 	"github.com/yaegashi/pswa/config"
 )
 
 type Core struct {
 	Root   string
-	Config *config.Config
-	Routes []*config.Route
 	Auth   *auth.Auth
+	config atomic.Pointer[config.Config]
 }
 
 func New(root string, cfg *config.Config, auth *auth.Auth) *Core {
-	routes := make([]*config.Route, len(cfg.Routes))
-	for i := 0; i < len(routes); i++ {
-		routes[i] = cfg.Routes[i]
-	}
-	return &Core{
-		Root:   root,
-		Config: cfg,
-		Auth:   auth,
-		Routes: routes,
+	c := &Core{
+		Root: root,
+		Auth: auth,
 	}
+	c.SetConfig(cfg)
+	return c
+}
+
+func (c *Core) Config() *config.Config {
+	return c.config.Load()
+}
+
+func (c *Core) SetConfig(cfg *config.Config) {
+	c.config.Store(cfg)
 }
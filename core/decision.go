@@ -0,0 +1,387 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/yaegashi/pswa/auth"
+	"github.com/yaegashi/pswa/config"
+)
+
+const (
+	ActionServe     = "serve"
+	ActionFallback  = "fallback"
+	ActionLogin     = "login"
+	ActionForbidden = "forbidden"
+	ActionRedirect  = "redirect"
+	ActionRewrite   = "rewrite"
+	ActionProxy     = "proxy"
+	ActionOptions   = "options"
+	ActionNoMethod  = "methodNotAllowed"
+	ActionNoAuth    = "unauthorized"
+)
+
+type Step struct {
+	Index  int    `json:"index"`
+	Route  string `json:"route"`
+	Match  bool   `json:"match"`
+	Reason string `json:"reason"`
+}
+
+// Decision describes how the middleware handles a request.
+type Decision struct {
+	Method        string            `json:"method"`
+	Path          string            `json:"path"`
+	Identity      *auth.Identity    `json:"identity"`
+	Steps         []Step            `json:"steps"`
+	RouteIndex    int               `json:"routeIndex"`
+	Route         *config.Route     `json:"-"`
+	Authorization string            `json:"authorization"`
+	Headers       map[string]string `json:"headers,omitempty"`
+	Action        string            `json:"action"`
+	Target        string            `json:"target,omitempty"`
+	Audit         string            `json:"audit,omitempty"`
+}
+
+func (d *Decision) setHeader(k, v string) {
+	if d.Headers == nil {
+		d.Headers = map[string]string{}
+	}
+	d.Headers[http.CanonicalHeaderKey(k)] = v
+}
+
+func (d *Decision) fallback(cfg *config.Config) *Decision {
+	d.Action = ActionServe
+	d.Target = d.Path
+	if cfg.NavigationFallback != nil {
+		for _, g := range cfg.NavigationFallback.Globbers {
+			if g.Match(d.Path) {
+				return d
+			}
+		}
+		d.Action = ActionFallback
+		d.Target = cfg.NavigationFallback.Rewrite
+		d.setHeader("Cache-Control", "no-cache")
+	}
+	return d
+}
+
+func requestPath(r *http.Request) string {
+	reqPath := filepath.Clean(r.URL.Path)
+	if strings.HasSuffix(r.URL.Path, "/") && !strings.HasPrefix(reqPath, "/") {
+		reqPath += "/"
+	}
+	return reqPath
+}
+
+// Decide evaluates cfg against the request r made by identity without side effects.
+func (c *Core) Decide(cfg *config.Config, r *http.Request, identity *auth.Identity) *Decision {
+	d := &Decision{
+		Method:     r.Method,
+		Path:       requestPath(r),
+		Identity:   identity,
+		RouteIndex: -1,
+	}
+
+	// The first route matching the path but not the method restricts the methods of the path
+	// to those of the routes with the same pattern, except deny-only routes which only filter.
+	var restricted *config.Route
+	allowed := map[string]struct{}{}
+	for i, rr := range cfg.Routes {
+		if !rr.Match(d.Path) {
+			d.Steps = append(d.Steps, Step{Index: i, Route: rr.Pattern(), Reason: "path does not match"})
+			continue
+		}
+		if rr.Conditions != nil {
+			if ok, reason := rr.Conditions.Match(r); !ok {
+				d.Steps = append(d.Steps, Step{Index: i, Route: rr.Pattern(), Reason: reason})
+				continue
+			}
+		}
+		if !cfg.MethodFallthrough {
+			if restricted != nil && rr.Pattern() != restricted.Pattern() {
+				d.Steps = append(d.Steps, Step{Index: i, Route: rr.Pattern(), Reason: fmt.Sprintf("methods restricted by route %q", restricted.Pattern())})
+				continue
+			}
+			if !rr.AllowsMethod(r.Method) {
+				d.Steps = append(d.Steps, Step{Index: i, Route: rr.Pattern(), Reason: fmt.Sprintf("method %s not in %v", r.Method, rr.Methods)})
+				if rr.DenyOnly() {
+					continue
+				}
+				if restricted == nil {
+					restricted = rr
+				}
+				for _, m := range rr.Methods {
+					allowed[strings.ToUpper(m)] = struct{}{}
+				}
+				continue
+			}
+		}
+		reason := "path matches"
+		if rr.Conditions != nil {
+			reason = "path and conditions match"
+		}
+		d.Steps = append(d.Steps, Step{Index: i, Route: rr.Pattern(), Match: true, Reason: reason})
+		d.RouteIndex = i
+		d.Route = rr
+		break
+	}
+
+	if d.Route == nil && len(allowed) > 0 {
+		allowed[http.MethodOptions] = struct{}{}
+		methods := make([]string, 0, len(allowed))
+		for m := range allowed {
+			methods = append(methods, m)
+		}
+		sort.Strings(methods)
+		d.setHeader("Allow", strings.Join(methods, ", "))
+		if r.Method == http.MethodOptions {
+			d.Authorization = "automatic OPTIONS response"
+			d.Action = ActionOptions
+		} else {
+			d.Authorization = fmt.Sprintf("method %s not allowed", r.Method)
+			d.Action = ActionNoMethod
+		}
+		return d
+	}
+
+	if d.Route == nil {
+		d.Authorization = "no route matched"
+		return d.fallback(cfg)
+	}
+	reqRoute := d.Route
+
+	if !reqRoute.AllowsMethod(r.Method) {
+		d.Authorization = fmt.Sprintf("method %s not in %v, falling through", r.Method, reqRoute.Methods)
+		return d.fallback(cfg)
+	}
+
+	if reqRoute.Protected() {
+		d.setHeader("Cache-Control", "no-cache")
+	}
+
+	for k, v := range reqRoute.Headers {
+		d.setHeader(k, v)
+	}
+
+	if !c.authorize(d, reqRoute, r, identity) {
+		if d.Action == ActionLogin && d.Identity == nil && cfg.BasicAuth != nil {
+			realm := cfg.BasicAuth.Realm
+			if realm == "" {
+				realm = config.DefaultBasicAuthRealm
+			}
+			d.Action = ActionNoAuth
+			d.Target = ""
+			d.setHeader("WWW-Authenticate", fmt.Sprintf("Basic realm=%q, charset=\"UTF-8\"", realm))
+		}
+		return d
+	}
+
+	if reqRoute.Redirect != "" {
+		d.Action = ActionRedirect
+		d.Target = reqRoute.Expand(reqRoute.Redirect, d.Path, true)
+		return d
+	}
+
+	if reqRoute.Rewrite != "" {
+		d.Action = ActionRewrite
+		d.Target = reqRoute.Expand(reqRoute.Rewrite, d.Path, false)
+		return d
+	}
+
+	if reqRoute.ProxyHandler != nil {
+		d.Action = ActionProxy
+		if reqRoute.ProxyExpand {
+			d.Target = reqRoute.Expand(reqRoute.Proxy, d.Path, true)
+		} else {
+			d.Target = reqRoute.Globber.StripPrefix(r.URL.Path)
+		}
+		return d
+	}
+
+	return d.fallback(cfg)
+}
+
+func (c *Core) login(d *Decision, r *http.Request) {
+	d.Action = ActionLogin
+	redirectPath := auth.LoginHandlerPath
+	if c.Auth.EasyAuth {
+		redirectPath = auth.EasyAuthHandlerPath
+	}
+	d.Target = fmt.Sprintf("%s?%s=%s", redirectPath, auth.ReturnValueName, url.QueryEscape(r.URL.String()))
+}
+
+// stepUp decides to sign in again with the prompt, acr_values and mfa parameters.
+func (c *Core) stepUp(d *Decision, r *http.Request, acr string, mfa bool) {
+	c.login(d, r)
+	d.Target += fmt.Sprintf("&%s=login", auth.PromptValueName)
+	if acr != "" {
+		d.Target += fmt.Sprintf("&%s=%s", auth.ACRValuesValueName, url.QueryEscape(acr))
+	}
+	if mfa {
+		d.Target += fmt.Sprintf("&%s=true", auth.MFAValueName)
+	}
+}
+
+// stepUpWindow is the period after a sign-in answering a step-up request in which it's not requested again,
+// and also the tolerance of the authentication time compared with the step-up request time.
+const stepUpWindow = time.Minute
+
+// stepUpOrForbid decides to sign in again if identity can satisfy a policy that way, or forbids otherwise.
+// EasyAuth sign-in doesn't pass prompt and acr_values to the identity provider,
+// and identities without the authentication time, e.g. basic auth, certificates, API keys
+// and the development identity provider, are not from OpenID Connect sign-in.
+// Identities just signed in again for a step-up request are also forbidden to prevent a sign-in loop.
+func (c *Core) stepUpOrForbid(d *Decision, r *http.Request, identity *auth.Identity, acr string, mfa bool) {
+	if c.Auth.EasyAuth || identity.AuthTime == 0 {
+		d.Authorization += ", unable to sign in again"
+		d.Action = ActionForbidden
+		return
+	}
+	authTime := time.Unix(identity.AuthTime, 0)
+	if identity.StepUp != 0 && time.Since(authTime) < stepUpWindow && authTime.After(time.Unix(identity.StepUp, 0).Add(-stepUpWindow)) {
+		d.Authorization += ", not satisfied by signing in again"
+		d.Action = ActionForbidden
+		return
+	}
+	c.stepUp(d, r, acr, mfa)
+}
+
+// checkPolicy evaluates the claims of identity against p.
+// It returns false if d is decided to sign in again or forbid.
+func (c *Core) checkPolicy(d *Decision, p *config.Policy, r *http.Request, identity *auth.Identity) bool {
+	if p.Tenants != nil {
+		found := false
+		for _, t := range p.Tenants {
+			if t == identity.TenantID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			d.Authorization = fmt.Sprintf("policy: tenant %q not in %v", identity.TenantID, p.Tenants)
+			d.Action = ActionForbidden
+			return false
+		}
+	}
+	if p.MFA {
+		found := false
+		for _, m := range identity.AMR {
+			if m == "mfa" {
+				found = true
+				break
+			}
+		}
+		if !found {
+			d.Authorization = "policy: multi-factor authentication required"
+			c.stepUpOrForbid(d, r, identity, p.ACR, p.MFA)
+			return false
+		}
+	}
+	if p.ACR != "" && identity.ACR != p.ACR {
+		d.Authorization = fmt.Sprintf("policy: authentication context %q required", p.ACR)
+		c.stepUpOrForbid(d, r, identity, p.ACR, p.MFA)
+		return false
+	}
+	if p.MaxAge > 0 && time.Since(time.Unix(identity.AuthTime, 0)) > p.MaxAge {
+		d.Authorization = fmt.Sprintf("policy: authentication older than %s", p.MaxAuthAge)
+		c.stepUpOrForbid(d, r, identity, p.ACR, p.MFA)
+		return false
+	}
+	return true
+}
+
+// authorize evaluates deny rules, then allowed roles of rr.
+// It returns false if d is decided to sign in or forbid.
+func (c *Core) authorize(d *Decision, rr *config.Route, r *http.Request, identity *auth.Identity) bool {
+	if rr.AllowAPIKey && identity == nil {
+		apiIdentity, err := c.Auth.APIKeyIdentity(r)
+		if err != nil {
+			d.Authorization = err.Error()
+			d.Audit = fmt.Sprintf("API key rejected: %s", err)
+			d.Action = ActionForbidden
+			return false
+		}
+		if apiIdentity != nil {
+			identity = apiIdentity
+			d.Identity = identity
+			d.Audit = fmt.Sprintf("API key %q accepted", identity.Id)
+		}
+	}
+
+	if rr.Deny {
+		d.Authorization = "denied by route"
+		d.Action = ActionForbidden
+		return false
+	}
+
+	for _, role := range rr.DeniedRoles {
+		switch {
+		case role == "anonymous" && identity == nil:
+			d.Authorization = "sign-in required: role \"anonymous\" denied"
+			c.login(d, r)
+			return false
+		case role == "anonymous":
+		case identity != nil && (role == "authenticated" || identity.HasRole(role)):
+			d.Authorization = fmt.Sprintf("denied by role %q", role)
+			d.Action = ActionForbidden
+			return false
+		}
+	}
+
+	if rr.AllowedRoles == nil && rr.RequiredRoles == nil && rr.RoleRequirement == nil && rr.Policy == nil {
+		d.Authorization = "anonymous allowed"
+		return true
+	}
+	if identity == nil {
+		d.Authorization = "sign-in required"
+		c.login(d, r)
+		return false
+	}
+
+	var granted []string
+	if rr.AllowedRoles != nil {
+		for _, role := range rr.AllowedRoles {
+			if identity.HasRole(role) {
+				granted = append(granted, fmt.Sprintf("role %q", role))
+				break
+			}
+		}
+		if granted == nil {
+			d.Authorization = fmt.Sprintf("none of roles %v", rr.AllowedRoles)
+			d.Action = ActionForbidden
+			return false
+		}
+	}
+	for _, role := range rr.RequiredRoles {
+		if !identity.HasRole(role) {
+			d.Authorization = fmt.Sprintf("required role %q missing", role)
+			d.Action = ActionForbidden
+			return false
+		}
+	}
+	if rr.RequiredRoles != nil {
+		granted = append(granted, fmt.Sprintf("all of roles %v", rr.RequiredRoles))
+	}
+	if rr.RoleRequirement != nil {
+		if !rr.RoleRequirement.Match(func(role string) bool { return identity.HasRole(role) }) {
+			d.Authorization = fmt.Sprintf("role requirement %s not satisfied", rr.RoleRequirement)
+			d.Action = ActionForbidden
+			return false
+		}
+		granted = append(granted, fmt.Sprintf("role requirement %s", rr.RoleRequirement))
+	}
+	if rr.Policy != nil {
+		if !c.checkPolicy(d, rr.Policy, r, identity) {
+			return false
+		}
+		granted = append(granted, "policy")
+	}
+	d.Authorization = "granted by " + strings.Join(granted, " and ")
+	return true
+}
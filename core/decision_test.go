@@ -0,0 +1,193 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http/httptest"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/yaegashi/pswa/auth"
+	"github.com/yaegashi/pswa/config"
+)
+
+type decideTest struct {
+	name     string
+	method   string
+	path     string
+	header   map[string]string
+	identity *auth.Identity
+	action   string
+	target   string
+	allow    string
+}
+
+func testDecide(t *testing.T, cfgJSON string, tests []decideTest) {
+	t.Helper()
+	ld := &config.Loader{}
+	cfg, ps := ld.Parse([]byte(cfgJSON))
+	if err := ps.Err(); err != nil {
+		t.Fatalf("Parse failed: %s", err)
+	}
+	c := New("", cfg, auth.New(cfg, nil))
+	for _, tt := range tests {
+		method := tt.method
+		if method == "" {
+			method = "GET"
+		}
+		r := httptest.NewRequest(method, tt.path, nil)
+		for k, v := range tt.header {
+			r.Header.Set(k, v)
+		}
+		d := c.Decide(cfg, r, tt.identity)
+		if d.Action != tt.action {
+			t.Errorf("%s: action = %q (%s), want %q", tt.name, d.Action, d.Authorization, tt.action)
+			continue
+		}
+		if tt.target != "" && d.Target != tt.target {
+			t.Errorf("%s: target = %q, want %q", tt.name, d.Target, tt.target)
+		}
+		if allow := d.Headers["Allow"]; allow != tt.allow {
+			t.Errorf("%s: Allow = %q, want %q", tt.name, allow, tt.allow)
+		}
+	}
+}
+
+func TestDecideMethods(t *testing.T) {
+	admin := &auth.Identity{Typ: "user", Id: "a", Roles: []string{"admin", "authenticated"}}
+
+	// A deny-only route restricted to methods filters only those methods
+	testDecide(t, `{"routes": [
+		{"route": "/*", "methods": ["PUT", "DELETE"], "deny": true},
+		{"route": "/admin/*", "allowedRoles": ["admin"]}
+	]}`, []decideTest{
+		{name: "GET public", path: "/index.html", action: ActionServe},
+		{name: "GET admin anonymous", path: "/admin/x", action: ActionLogin},
+		{name: "GET admin", path: "/admin/x", identity: admin, action: ActionServe},
+		{name: "PUT denied", method: "PUT", path: "/admin/x", identity: admin, action: ActionForbidden},
+	})
+
+	// A broader route later doesn't serve a method-restricted path with another method
+	testDecide(t, `{"routes": [
+		{"route": "/admin/*", "methods": ["GET"], "allowedRoles": ["admin"]},
+		{"route": "/*", "methods": ["POST"]}
+	]}`, []decideTest{
+		{name: "POST admin", method: "POST", path: "/admin/x", action: ActionNoMethod, allow: "GET, OPTIONS"},
+		{name: "OPTIONS admin", method: "OPTIONS", path: "/admin/x", action: ActionOptions, allow: "GET, OPTIONS"},
+		{name: "HEAD admin", method: "HEAD", path: "/admin/x", action: ActionLogin},
+		{name: "POST public", method: "POST", path: "/x", action: ActionServe},
+		{name: "GET public", path: "/x", action: ActionNoMethod, allow: "OPTIONS, POST"},
+	})
+
+	// Routes with the same pattern are alternatives for methods
+	testDecide(t, `{"routes": [
+		{"route": "/api/*", "methods": ["GET"], "allowedRoles": ["reader"]},
+		{"route": "/api/*", "methods": ["POST"], "allowedRoles": ["admin"]}
+	]}`, []decideTest{
+		{name: "POST api", method: "POST", path: "/api/x", identity: admin, action: ActionServe},
+		{name: "GET api", path: "/api/x", identity: admin, action: ActionForbidden},
+		{name: "DELETE api", method: "DELETE", path: "/api/x", identity: admin, action: ActionNoMethod, allow: "GET, OPTIONS, POST"},
+	})
+
+	// methodFallthrough falls through to the file handler
+	testDecide(t, `{"methodFallthrough": true, "routes": [
+		{"route": "/admin/*", "methods": ["GET"], "allowedRoles": ["admin"]}
+	]}`, []decideTest{
+		{name: "POST fallthrough", method: "POST", path: "/admin/x", action: ActionServe},
+	})
+}
+
+func TestDecideStepUp(t *testing.T) {
+	now := time.Now().Unix()
+	user := &auth.Identity{Typ: "user", Id: "u", Roles: []string{"authenticated"}, AMR: []string{"pwd"}, AuthTime: now - 3600}
+	steppedUp := &auth.Identity{Typ: "user", Id: "u", Roles: []string{"authenticated"}, AMR: []string{"pwd"}, AuthTime: now, StepUp: now - 10}
+	steppedUpBefore := &auth.Identity{Typ: "user", Id: "u", Roles: []string{"authenticated"}, AMR: []string{"pwd"}, AuthTime: now - 3600, StepUp: now - 3610}
+	testDecide(t, `{"routes": [
+		{"route": "/mfa/*", "policy": {"mfa": true}},
+		{"route": "/acr/*", "policy": {"acr": "c1"}}
+	]}`, []decideTest{
+		{name: "mfa", path: "/mfa/x", identity: user, action: ActionLogin, target: "/.auth/pswa/login?return=%2Fmfa%2Fx&prompt=login&mfa=true"},
+		{name: "acr", path: "/acr/x", identity: user, action: ActionLogin, target: "/.auth/pswa/login?return=%2Facr%2Fx&prompt=login&acr_values=c1"},
+		{name: "mfa after step-up", path: "/mfa/x", identity: steppedUp, action: ActionForbidden},
+		{name: "acr after step-up", path: "/acr/x", identity: steppedUp, action: ActionForbidden},
+		{name: "mfa after old step-up", path: "/mfa/x", identity: steppedUpBefore, action: ActionLogin},
+	})
+}
+
+func TestDecideAuthorization(t *testing.T) {
+	now := time.Now().Unix()
+	identity := func(roles ...string) *auth.Identity {
+		roles = append(roles, "authenticated")
+		sort.Strings(roles)
+		return &auth.Identity{Typ: "user", Id: "u", Roles: roles, TenantID: "t1", AMR: []string{"pwd"}, AuthTime: now}
+	}
+	mfaUser := identity()
+	mfaUser.AMR = []string{"pwd", "mfa"}
+	oldUser := identity()
+	oldUser.AuthTime = now - 3600
+	basicUser := &auth.Identity{Typ: "basic", Id: "b", Roles: []string{"authenticated"}}
+	testDecide(t, `{"routes": [
+		{"route": "/deny/*", "deny": true, "allowedRoles": ["admin"]},
+		{"route": "/denied/*", "deniedRoles": ["banned"], "allowedRoles": ["admin", "banned"]},
+		{"route": "/members/*", "deniedRoles": ["anonymous"]},
+		{"route": "/guests/*", "deniedRoles": ["authenticated"]},
+		{"route": "/allowed/*", "allowedRoles": ["reader", "writer"]},
+		{"route": "/required/*", "requiredRoles": ["reader", "writer"]},
+		{"route": "/both/*", "allowedRoles": ["admin"], "requiredRoles": ["reader"]},
+		{"route": "/nested/*", "roleRequirement": {"anyOf": [{"role": "admin"}, {"allOf": [{"role": "finance"}, {"role": "auditor"}]}]}},
+		{"route": "/tenant/*", "policy": {"tenants": ["t2"]}},
+		{"route": "/mfa/*", "policy": {"mfa": true}},
+		{"route": "/age/*", "policy": {"maxAuthAge": "15m"}}
+	]}`, []decideTest{
+		{name: "deny before allow", path: "/deny/x", identity: identity("admin"), action: ActionForbidden},
+		{name: "deny anonymous", path: "/deny/x", action: ActionForbidden},
+		{name: "denied role before allow", path: "/denied/x", identity: identity("admin", "banned"), action: ActionForbidden},
+		{name: "allowed without denied role", path: "/denied/x", identity: identity("admin"), action: ActionServe},
+		{name: "anonymous denied", path: "/members/x", action: ActionLogin},
+		{name: "anonymous denied signed in", path: "/members/x", identity: identity(), action: ActionServe},
+		{name: "authenticated denied", path: "/guests/x", identity: identity(), action: ActionForbidden},
+		{name: "authenticated denied anonymous", path: "/guests/x", action: ActionServe},
+		{name: "allowed any role", path: "/allowed/x", identity: identity("writer"), action: ActionServe},
+		{name: "allowed no role", path: "/allowed/x", identity: identity("other"), action: ActionForbidden},
+		{name: "allowed anonymous", path: "/allowed/x", action: ActionLogin},
+		{name: "required all roles", path: "/required/x", identity: identity("reader", "writer"), action: ActionServe},
+		{name: "required missing role", path: "/required/x", identity: identity("reader"), action: ActionForbidden},
+		{name: "allowed and required", path: "/both/x", identity: identity("admin", "reader"), action: ActionServe},
+		{name: "allowed without required", path: "/both/x", identity: identity("admin"), action: ActionForbidden},
+		{name: "nested any", path: "/nested/x", identity: identity("admin"), action: ActionServe},
+		{name: "nested all", path: "/nested/x", identity: identity("auditor", "finance"), action: ActionServe},
+		{name: "nested partial", path: "/nested/x", identity: identity("finance"), action: ActionForbidden},
+		{name: "policy tenant forbidden", path: "/tenant/x", identity: identity(), action: ActionForbidden},
+		{name: "policy mfa step-up", path: "/mfa/x", identity: identity(), action: ActionLogin},
+		{name: "policy mfa satisfied", path: "/mfa/x", identity: mfaUser, action: ActionServe},
+		{name: "policy mfa without OpenID Connect", path: "/mfa/x", identity: basicUser, action: ActionForbidden},
+		{name: "policy age step-up", path: "/age/x", identity: oldUser, action: ActionLogin},
+		{name: "policy age satisfied", path: "/age/x", identity: identity(), action: ActionServe},
+		{name: "policy anonymous", path: "/age/x", action: ActionLogin},
+	})
+}
+
+func TestDecideAPIKey(t *testing.T) {
+	hash := func(key string) string {
+		sum := sha256.Sum256([]byte(key))
+		return hex.EncodeToString(sum[:])
+	}
+	testDecide(t, fmt.Sprintf(`{
+		"apiKeys": [
+			{"id": "ci", "hash": %q, "roles": ["uploader"]},
+			{"id": "old", "hash": %q, "roles": ["uploader"], "expires": "2000-01-01T00:00:00Z"}
+		],
+		"routes": [
+			{"route": "/upload/*", "allowApiKey": true, "allowedRoles": ["uploader"]},
+			{"route": "/admin/*", "allowedRoles": ["uploader"]}
+		]
+	}`, hash("valid"), hash("expired")), []decideTest{
+		{name: "accepted", path: "/upload/x", header: map[string]string{"X-Api-Key": "valid"}, action: ActionServe},
+		{name: "expired", path: "/upload/x", header: map[string]string{"X-Api-Key": "expired"}, action: ActionForbidden},
+		{name: "unknown", path: "/upload/x", header: map[string]string{"X-Api-Key": "unknown"}, action: ActionForbidden},
+		{name: "missing", path: "/upload/x", action: ActionLogin},
+		{name: "not allowed on route", path: "/admin/x", header: map[string]string{"X-Api-Key": "valid"}, action: ActionLogin},
+	})
+}
@@ -0,0 +1,73 @@
+package core
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/yaegashi/pswa/auth"
+)
+
+const (
+	ExplainHandlerPath = "/.auth/pswa/explain"
+)
+
+// RolesIdentity returns a hypothetical identity with comma-separated roles signed in just now,
+// or nil for an anonymous user if roles is empty.
+func RolesIdentity(roles string) *auth.Identity {
+	if roles == "" {
+		return nil
+	}
+	roleMap := map[string]struct{}{"authenticated": {}}
+	for _, role := range strings.Split(roles, ",") {
+		role = strings.ToLower(strings.TrimSpace(role))
+		if role != "" {
+			roleMap[role] = struct{}{}
+		}
+	}
+	identity := &auth.Identity{Typ: "user", AuthTime: time.Now().Unix()}
+	for role := range roleMap {
+		identity.Roles = append(identity.Roles, role)
+	}
+	sort.Strings(identity.Roles)
+	return identity
+}
+
+func (c *Core) ExplainHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-cache")
+
+	cfg := c.Config()
+	if cfg.AdminRole == "" {
+		httpWriteError(w, r, http.StatusNotFound, "")
+		return
+	}
+	identity := c.Auth.Identity(r)
+	if identity == nil || !identity.HasRole(cfg.AdminRole) {
+		httpWriteError(w, r, http.StatusForbidden, "")
+		return
+	}
+
+	method := r.FormValue("method")
+	if method == "" {
+		method = http.MethodGet
+	}
+	path := r.FormValue("path")
+	if path == "" {
+		path = "/"
+	}
+	if _, ok := r.Form["roles"]; ok {
+		identity = RolesIdentity(r.FormValue("roles"))
+	}
+	req, err := http.NewRequestWithContext(r.Context(), strings.ToUpper(method), path, nil)
+	if err != nil {
+		httpWriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	d := c.Decide(cfg, req, identity)
+	b, _ := json.MarshalIndent(d, "", "  ")
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
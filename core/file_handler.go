@@ -19,8 +19,28 @@ func fileErrorStatus(err error) (httpStatus int) {
 	return http.StatusInternalServerError
 }
 
+// configFile reports whether p is one of the files read for the config,
+// which may contain secrets and must not be served.
+func (c *Core) configFile(p string) bool {
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return true
+	}
+	for _, file := range c.Config().Files {
+		f, err := filepath.Abs(file)
+		if err == nil && f == abs {
+			return true
+		}
+	}
+	return false
+}
+
 func (c *Core) FileHandler(w http.ResponseWriter, r *http.Request) {
 	p := filepath.Join(c.Root, filepath.Clean(r.URL.Path))
+	if c.configFile(p) {
+		httpWriteError(w, r, http.StatusNotFound, "")
+		return
+	}
 	if !strings.HasSuffix(p, "/index.html") {
 		http.ServeFile(w, r, p)
 		return
@@ -1,15 +1,9 @@
 package core
 
 import (
-	"fmt"
 	"net/http"
 	"net/url"
-	"path/filepath"
-	"sort"
-	"strings"
 
-	"github.com/yaegashi/pswa/auth"
-	"github.com/yaegashi/pswa/config"
 	"github.com/yaegashi/pswa/logging"
 )
 
@@ -18,119 +12,71 @@ func (c *Core) NewMiddleware() func(http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			logger := logging.Logger(r.Context()).Sugar()
 
-			identity := c.Auth.Identity(r)
-
-			reqPath := filepath.Clean(r.URL.Path)
-			if strings.HasSuffix(r.URL.Path, "/") && !strings.HasPrefix(reqPath, "/") {
-				reqPath += "/"
-			}
-
-			var reqRoute *config.Route
-			for _, rr := range c.Routes {
-				if rr.Globber.Match(reqPath) {
-					reqRoute = rr
-					break
+			cfg := c.Config()
+			identity := c.Auth.IdentityWithoutBasic(r)
+			d := c.Decide(cfg, r, identity)
+			// Verify basic auth credentials only for protected routes
+			if identity == nil && d.Route != nil && d.Route.Protected() {
+				identity = c.Auth.LoggedBasicIdentity(r)
+				if identity != nil {
+					d = c.Decide(cfg, r, identity)
 				}
 			}
 
-			//logger.Debugf("path=%#v route=%#v", reqPath, reqRoute)
+			//logger.Debugf("decision=%#v", d)
 
-			fallback := func() {
-				if c.Config.NavigationFallback != nil {
-					ok := false
-					for _, g := range c.Config.NavigationFallback.Globbers {
-						if g.Match(reqPath) {
-							ok = true
-							break
-						}
-					}
-					if !ok {
-						r = r.Clone(r.Context())
-						r.URL.Path = c.Config.NavigationFallback.Rewrite
-						r.URL.RawPath = c.Config.NavigationFallback.Rewrite
-						w.Header().Set("Cache-Control", "no-cache")
-					}
-				}
-				next.ServeHTTP(w, r)
+			if identity != nil && identity.Impersonator != nil {
+				logger.Infow("Impersonated request", "impersonator", identity.Impersonator.Id, "id", identity.Id, "roles", identity.Roles)
 			}
 
-			if reqRoute == nil {
-				fallback()
-				return
+			if d.Audit != "" {
+				logger.Infow(d.Audit, "audit", "apikey", "method", d.Method, "path", d.Path, "authorization", d.Authorization, "action", d.Action)
 			}
 
-			if reqRoute.Methods != nil {
-				ok := false
-				method := strings.ToLower(r.Method)
-				for _, m := range reqRoute.Methods {
-					if strings.ToLower(m) == method {
-						ok = true
-						break
-					}
-				}
-				if !ok {
-					fallback()
-					return
-				}
-			}
-
-			if reqRoute.AllowedRoles != nil {
-				w.Header().Set("Cache-Control", "no-cache")
+			for k, v := range d.Headers {
+				w.Header().Set(k, v)
 			}
 
-			if reqRoute.Headers != nil {
-				for k, v := range reqRoute.Headers {
-					w.Header().Set(k, v)
+			switch d.Action {
+			case ActionLogin, ActionRedirect:
+				http.Redirect(w, r, d.Target, http.StatusFound)
+			case ActionForbidden:
+				httpWriteError(w, r, http.StatusForbidden, "")
+			case ActionNoAuth:
+				httpWriteError(w, r, http.StatusUnauthorized, "")
+			case ActionNoMethod:
+				httpWriteError(w, r, http.StatusMethodNotAllowed, "")
+			case ActionOptions:
+				w.WriteHeader(http.StatusNoContent)
+			case ActionRewrite, ActionFallback:
+				r = r.Clone(r.Context())
+				r.URL.Path = d.Target
+				r.URL.RawPath = d.Target
+				next.ServeHTTP(w, r)
+			case ActionProxy:
+				r = r.Clone(r.Context())
+				if d.Identity != nil && d.Identity.Typ == "apikey" {
+					r.Header.Del(c.Auth.APIKeyHeader())
 				}
-			}
-
-			if reqRoute.AllowedRoles != nil {
-				if identity == nil {
-					redirectPath := auth.LoginHandlerPath
-					if c.Auth.EasyAuth {
-						redirectPath = auth.EasyAuthHandlerPath
+				if d.Route.ProxyExpand {
+					u, err := url.Parse(d.Target)
+					if err != nil {
+						httpWriteError(w, r, http.StatusBadGateway, "")
+						return
 					}
-					redirectURL := fmt.Sprintf("%s?%s=%s", redirectPath, auth.ReturnValueName, url.QueryEscape(r.URL.String()))
-					http.Redirect(w, r, redirectURL, http.StatusFound)
-					return
-				}
-				ok := false
-				for _, role := range reqRoute.AllowedRoles {
-					n := sort.Search(len(identity.Roles), func(i int) bool { return identity.Roles[i] >= role })
-					if n < len(identity.Roles) && identity.Roles[n] == role {
-						ok = true
-						break
+					if u.RawQuery == "" {
+						u.RawQuery = r.URL.RawQuery
 					}
+					r.URL = u
+				} else {
+					r.URL.Path = d.Target
+					r.URL.RawPath = r.URL.Path
 				}
-				if !ok {
-					httpWriteError(w, r, http.StatusForbidden, "")
-					return
-				}
-			}
-
-			if reqRoute.Redirect != "" {
-				http.Redirect(w, r, reqRoute.Redirect, http.StatusFound)
-				return
-			}
-
-			if reqRoute.Rewrite != "" {
-				r = r.Clone(r.Context())
-				r.URL.Path = reqRoute.Rewrite
-				r.URL.RawPath = reqRoute.Rewrite
-				next.ServeHTTP(w, r)
-				return
-			}
-
-			if reqRoute.ProxyHandler != nil {
-				r = r.Clone(r.Context())
-				r.URL.Path = reqRoute.Globber.StripPrefix(r.URL.Path)
-				r.URL.RawPath = r.URL.Path
 				logger.Debugf("redirect to: %s", r.URL)
-				reqRoute.ProxyHandler.ServeHTTP(w, r)
-				return
+				d.Route.ProxyHandler.ServeHTTP(w, r)
+			default:
+				next.ServeHTTP(w, r)
 			}
-
-			fallback()
 		})
 	}
 }
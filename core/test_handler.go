@@ -47,5 +47,5 @@ func (c *Core) TestHandler(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, `<p>Identity stored in the cookie:</p><pre>%s</pre>`, htmlDump(identity))
 
 	// PSWA Configuration
-	fmt.Fprintf(w, `<p>PSWA configuration:</p><pre>%s</pre>`, htmlDump(c.Config))
+	fmt.Fprintf(w, `<p>PSWA configuration:</p><pre>%s</pre>`, htmlDump(c.Config().Redacted()))
 }
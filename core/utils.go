@@ -14,6 +14,7 @@ func htmlDump(v any) string {
 
 func httpWriteError(w http.ResponseWriter, r *http.Request, status int, msg string) {
 	w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+	w.WriteHeader(status)
 
 	fmt.Fprintf(w, `<h1>%d %s</h1>`, status, http.StatusText(status))
 	if msg != "" {
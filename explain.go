@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/yaegashi/pswa/auth"
+	"github.com/yaegashi/pswa/config"
+	"github.com/yaegashi/pswa/core"
+)
+
+func explainMain(args []string) int {
+	fs := flag.NewFlagSet("explain", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: pswa explain [options]\n")
+		fs.PrintDefaults()
+	}
+	configPath := fs.String("config", DefaultConfig, "config file")
+	method := fs.String("method", http.MethodGet, "request method")
+	path := fs.String("path", "/", "request path with optional query string")
+	roles := fs.String("roles", "", "comma-separated roles of the user (anonymous if empty)")
+	env := fs.String("env", os.Getenv(EnvConfigEnv), "environment of the config overlay")
+	jsonOutput := fs.Bool("json", false, "output in JSON")
+	var headers, cookies stringsFlag
+	fs.Var(&headers, "header", "request header in the form of `name: value` (repeatable)")
+	fs.Var(&cookies, "cookie", "request cookie in the form of `name=value` (repeatable)")
+	fs.Parse(args)
+
+	loader := &config.Loader{Environment: *env}
+	cfg, ps := loader.Load(*configPath)
+	err := ps.Err()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	req, err := http.NewRequest(strings.ToUpper(*method), *path, nil)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	for _, h := range headers {
+		k, v, _ := strings.Cut(h, ":")
+		req.Header.Add(strings.TrimSpace(k), strings.TrimSpace(v))
+	}
+	for _, c := range cookies {
+		k, v, _ := strings.Cut(c, "=")
+		req.AddCookie(&http.Cookie{Name: k, Value: v})
+	}
+	c := core.New("", cfg, auth.New(cfg, nil))
+	d := c.Decide(cfg, req, core.RolesIdentity(*roles))
+
+	if *jsonOutput {
+		b, _ := json.MarshalIndent(d, "", "  ")
+		fmt.Println(string(b))
+		return 0
+	}
+
+	fmt.Printf("Request:       %s %s\n", d.Method, d.Path)
+	if d.Identity == nil {
+		fmt.Printf("Identity:      anonymous\n")
+	} else if d.Identity.Id != "" {
+		fmt.Printf("Identity:      %s %q roles %v\n", d.Identity.Typ, d.Identity.Id, d.Identity.Roles)
+	} else {
+		fmt.Printf("Identity:      roles %v\n", d.Identity.Roles)
+	}
+	for _, step := range d.Steps {
+		result := "skipped"
+		if step.Match {
+			result = "matched"
+		}
+		fmt.Printf("Route:         [%d] %q %s: %s\n", step.Index, step.Route, result, step.Reason)
+	}
+	fmt.Printf("Authorization: %s\n", d.Authorization)
+	if d.Audit != "" {
+		fmt.Printf("Audit:         %s\n", d.Audit)
+	}
+	keys := make([]string, 0, len(d.Headers))
+	for k := range d.Headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Printf("Header:        %s: %s\n", k, d.Headers[k])
+	}
+	if d.Action == core.ActionProxy && !d.Route.ProxyExpand {
+		fmt.Printf("Action:        %s %s%s\n", d.Action, d.Route.Proxy, d.Target)
+	} else {
+		fmt.Printf("Action:        %s %s\n", d.Action, d.Target)
+	}
+	return 0
+}
+
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ", ")
+}
+
+func (f *stringsFlag) Set(s string) error {
+	*f = append(*f, s)
+	return nil
+}
@@ -2,52 +2,75 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"net/http"
 	"os"
-	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/gorilla/sessions"
-	"github.com/yaegashi/pswa/auth"
 	"github.com/yaegashi/pswa/config"
-	"github.com/yaegashi/pswa/core"
 	"github.com/yaegashi/pswa/logging"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
 const (
-	EnvTenantID     = "PSWA_TENANT_ID"
-	EnvClientID     = "PSWA_CLIENT_ID"
-	EnvClientSecret = "PSWA_CLIENT_SECRET"
-	EnvRedirectURI  = "PSWA_REDIRECT_URI"
-	EnvAuthParams   = "PSWA_AUTH_PARAMS"
-	EnvSessionKey   = "PSWA_SESSION_KEY"
-	EnvListen       = "PSWA_LISTEN"
-	EnvWWWRoot      = "PSWA_WWW_ROOT"
-	EnvTestRoot     = "PSWA_TEST_ROOT"
-	EnvConfig       = "PSWA_CONFIG"
-	DefaultListen   = ":8080"
-	DefaultWWWRoot  = "/home/site/wwwroot"
-	DefaultTestRoot = "/testroot"
-	DefaultConfig   = "pswa.config.json"
+	EnvTenantID      = "PSWA_TENANT_ID"
+	EnvClientID      = "PSWA_CLIENT_ID"
+	EnvClientSecret  = "PSWA_CLIENT_SECRET"
+	EnvRedirectURI   = "PSWA_REDIRECT_URI"
+	EnvAuthParams    = "PSWA_AUTH_PARAMS"
+	EnvSessionKey    = "PSWA_SESSION_KEY"
+	EnvListen        = "PSWA_LISTEN"
+	EnvWWWRoot       = "PSWA_WWW_ROOT"
+	EnvTestRoot      = "PSWA_TEST_ROOT"
+	EnvConfig        = "PSWA_CONFIG"
+	EnvConfigWatch   = "PSWA_CONFIG_WATCH"
+	EnvConfigLenient = "PSWA_CONFIG_LENIENT"
+	EnvConfigEnv     = "PSWA_ENV"
+	EnvHosts         = "PSWA_HOSTS"
+	EnvStrict        = "PSWA_STRICT"
+	EnvTLSCert       = "PSWA_TLS_CERT"
+	EnvTLSKey        = "PSWA_TLS_KEY"
+	EnvTLSClientCA   = "PSWA_TLS_CLIENT_CA"
+	EnvDevAuth       = "PSWA_DEV_AUTH"
+	DefaultListen    = ":8080"
+	DefaultWWWRoot   = "/home/site/wwwroot"
+	DefaultTestRoot  = "/testroot"
+	DefaultConfig    = "pswa.config.json"
+	DefaultStrict    = true
+	HealthPath       = "/.auth/pswa/health"
 )
 
 type App struct {
-	SessionStore *sessions.CookieStore
-	Config       *config.Config
-	Auth         *auth.Auth
-	Core         *core.Core
-	TenantID     string
-	ClientID     string
-	ClientSecret string
-	RedirectURI  string
-	AuthParams   string
-	SessionKey   string
-	Listen       string
-	WWWRootPath  string
-	TestRootPath string
-	ConfigPath   string
+	SessionStore  *sessions.CookieStore
+	DefaultSite   *Site
+	Sites         []*Site
+	Hosts         []*config.Host
+	TenantID      string
+	ClientID      string
+	ClientSecret  string
+	RedirectURI   string
+	AuthParams    string
+	SessionKey    string
+	Listen        string
+	WWWRootPath   string
+	TestRootPath  string
+	ConfigPath    string
+	ConfigWatch   time.Duration
+	ConfigLenient bool
+	ConfigEnv     string
+	HostsPath     string
+	Strict        bool
+	TLSCert       string
+	TLSKey        string
+	TLSClientCA   string
+	DevAuth       bool
+	Settings      []*Setting
+	loggers       *zap.SugaredLogger
 }
 
 func (app *App) Main(ctx context.Context) error {
@@ -59,88 +82,139 @@ func (app *App) Main(ctx context.Context) error {
 	}
 	defer logger.Sync()
 	loggers := logger.WithOptions(zap.WithCaller(false)).Sugar()
+	app.loggers = loggers
 
 	app.SessionStore = sessions.NewCookieStore([]byte(app.SessionKey))
 
-	configPath := app.ConfigPath
-	if !filepath.IsAbs(configPath) {
-		configPath = filepath.Join(app.WWWRootPath, configPath)
+	site := &Site{
+		app:         app,
+		loggers:     loggers,
+		WWWRootPath: app.WWWRootPath,
+		ConfigPath:  app.ConfigPath,
 	}
-	loggers.Infof("Reading config: %s", configPath)
-	app.Config, err = config.New(configPath)
+	site.ReadConfig()
+	err = app.ApplyServerConfig(site.Config.Server)
 	if err != nil {
-		loggers.Errorf("Reading config failed: %s", err)
-		app.Config = config.Unconfigured
+		return err
 	}
-
-	app.Auth = auth.New(app.Config, app.SessionStore)
-	loggers.Infof("OpenID Connect auth config:")
-	loggers.Infof("  TenantID    = %s", app.TenantID)
-	loggers.Infof("  ClientID    = %s", app.ClientID)
-	loggers.Infof("  RedirectURI = %s", app.RedirectURI)
-	loggers.Infof("  AuthParams  = %s", app.AuthParams)
-
-	if app.Auth.EasyAuth {
-		loggers.Infof("EasyAuth enabled, skipping OpenID Connect auth config")
-	} else if app.TenantID == "" || app.ClientID == "" || app.ClientSecret == "" || app.RedirectURI == "" {
-		loggers.Errorf("OpenID Connect auth config missing")
-	} else {
-		err = app.Auth.ConfigureOIDC(app.TenantID, app.ClientID, app.ClientSecret, app.RedirectURI, app.AuthParams)
+	site.TenantID = app.TenantID
+	site.ClientID = app.ClientID
+	site.ClientSecret = app.ClientSecret
+	site.RedirectURI = app.RedirectURI
+	site.AuthParams = app.AuthParams
+	site.TestRootPath = app.TestRootPath
+	site.Setup()
+	app.DefaultSite = site
+
+	if app.HostsPath != "" {
+		loggers.Infof("Reading hosts: %s", app.HostsPath)
+		loader := &config.Loader{Lenient: app.ConfigLenient}
+		hosts, ps := loader.LoadHosts(app.HostsPath)
+		for _, p := range ps.Warnings() {
+			loggers.Warn(p)
+		}
+		err = ps.Err()
 		if err != nil {
-			loggers.Errorf("OpenID Connect auth config failed: %s", err)
+			return err
+		}
+		for _, h := range hosts.Hosts {
+			site := &Site{
+				app:          app,
+				loggers:      loggers.With("host", h.Host),
+				Host:         h.Host,
+				WWWRootPath:  h.WWWRoot,
+				ConfigPath:   h.Config,
+				TestRootPath: h.TestRoot,
+				TenantID:     h.TenantID,
+				ClientID:     h.ClientID,
+				ClientSecret: h.ClientSecret,
+				RedirectURI:  h.RedirectURI,
+				AuthParams:   h.AuthParams,
+			}
+			if site.ConfigPath == "" {
+				site.ConfigPath = DefaultConfig
+			}
+			if site.TestRootPath == "" {
+				site.TestRootPath = app.TestRootPath
+			}
+			if site.TenantID == "" && site.ClientID == "" {
+				site.TenantID = app.TenantID
+				site.ClientID = app.ClientID
+				site.ClientSecret = app.ClientSecret
+				site.AuthParams = app.AuthParams
+			}
+			site.ReadConfig()
+			if site.Config.Server != nil {
+				site.loggers.Warnf("Server section ignored for hosts")
+			}
+			site.Setup()
+			app.Hosts = append(app.Hosts, h)
+			app.Sites = append(app.Sites, site)
 		}
 	}
 
-	root := app.WWWRootPath
-	if app.Config.TestRoot {
-		loggers.Warnf("TestRoot enabled")
-		root = app.TestRootPath
-	}
-	loggers.Infof("Serving from root path %s", root)
-	app.Core = core.New(root, app.Config, app.Auth)
+	go app.WatchConfig(ctx)
 
-	mux := http.NewServeMux()
-	app.Auth.RegisterHandlers(mux)
+	handler := logging.NewMiddleware(logger)(app.HostHandler())
 
-	coreHandler := app.Core.FileHandler
-	if app.Config.TestHandler {
-		coreHandler = app.Core.TestHandler
+	server := &http.Server{Addr: app.Listen, Handler: handler}
+	if app.TLSCert == "" {
+		if app.TLSClientCA != "" {
+			return fmt.Errorf("TLS client CA requires TLS certificate")
+		}
+		loggers.Infof("Serving on %s", app.Listen)
+		return server.ListenAndServe()
 	}
-	mux.Handle("/", app.Core.NewMiddleware()(http.HandlerFunc(coreHandler)))
 
-	handler := logging.NewMiddleware(logger)(mux)
+	server.TLSConfig = &tls.Config{}
+	if app.TLSClientCA != "" {
+		b, err := os.ReadFile(app.TLSClientCA)
+		if err != nil {
+			return err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(b) {
+			return fmt.Errorf("No certificates in TLS client CA %s", app.TLSClientCA)
+		}
+		server.TLSConfig.ClientCAs = pool
+		server.TLSConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		loggers.Infof("Verifying client certificates with %s", app.TLSClientCA)
+	}
 
-	loggers.Infof("Serving on %s", app.Listen)
+	loggers.Infof("Serving TLS on %s", app.Listen)
 
-	return http.ListenAndServe(app.Listen, handler)
+	return server.ListenAndServeTLS(app.TLSCert, app.TLSKey)
 }
 
 func main() {
-	app := &App{
-		TenantID:     os.Getenv(EnvTenantID),
-		ClientID:     os.Getenv(EnvClientID),
-		ClientSecret: os.Getenv(EnvClientSecret),
-		RedirectURI:  os.Getenv(EnvRedirectURI),
-		AuthParams:   os.Getenv(EnvAuthParams),
-		SessionKey:   os.Getenv(EnvSessionKey),
-		Listen:       os.Getenv(EnvListen),
-		WWWRootPath:  os.Getenv(EnvWWWRoot),
-		TestRootPath: os.Getenv(EnvTestRoot),
-		ConfigPath:   os.Getenv(EnvConfig),
-	}
-	if app.Listen == "" {
-		app.Listen = DefaultListen
+	cmd, args := "", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd, args = args[0], args[1:]
 	}
-	if app.WWWRootPath == "" {
-		app.WWWRootPath = DefaultWWWRoot
+	switch cmd {
+	case "":
+	case "validate":
+		os.Exit(validateMain(args))
+	case "explain":
+		os.Exit(explainMain(args))
+	case "schema":
+		os.Exit(schemaMain(args))
+	case "print-config":
+		os.Exit(printConfigMain(args))
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command: %s\n", cmd)
+		os.Exit(2)
 	}
-	if app.TestRootPath == "" {
-		app.TestRootPath = DefaultTestRoot
+	app := &App{}
+	fs := app.NewFlagSet("pswa")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: pswa [options]\n       pswa validate|explain|schema|print-config [options]\n")
+		fs.PrintDefaults()
 	}
-	if app.ConfigPath == "" {
-		app.ConfigPath = DefaultConfig
+	err := app.ParseSettings(fs, args)
+	if err == nil {
+		err = app.Main(context.Background())
 	}
-	err := app.Main(context.Background())
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
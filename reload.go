@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"reflect"
+	"syscall"
+	"time"
+
+	"github.com/yaegashi/pswa/config"
+)
+
+func (site *Site) LoadConfig() (*config.Config, error) {
+	loader := &config.Loader{Lenient: site.app.ConfigLenient, Environment: site.app.ConfigEnv}
+	cfg, ps := loader.Load(site.configPath)
+	for _, p := range ps.Warnings() {
+		site.loggers.Warn(p)
+	}
+	err := ps.Err()
+	if err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (site *Site) ReloadConfig() {
+	loggers := site.loggers
+	loggers.Infof("Reloading config: %s", site.configPath)
+	cfg, err := site.LoadConfig()
+	if err != nil {
+		loggers.Errorf("Reloading config failed, keeping previous config: %s", err)
+		return
+	}
+	if cfg.TestHandler != site.Config.TestHandler || cfg.TestRoot != site.Config.TestRoot {
+		loggers.Warnf("Changes to testHandler and testRoot take effect after restart")
+	}
+	if !reflect.DeepEqual(cfg.Server, site.Config.Server) {
+		loggers.Warnf("Changes to server take effect after restart")
+	}
+	site.Config = cfg
+	site.Auth.SetConfig(cfg)
+	site.Core.SetConfig(cfg)
+	loggers.Infof("Reloading config succeeded")
+	if site.configFailed.Swap(false) && !site.Unavailable() {
+		loggers.Infof("Strict mode enabled, resuming service")
+	}
+}
+
+func (app *App) WatchConfig(ctx context.Context) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGHUP)
+	defer signal.Stop(sigCh)
+
+	var tickCh <-chan time.Time
+	if app.ConfigWatch > 0 {
+		app.loggers.Infof("Watching config every %s", app.ConfigWatch)
+		ticker := time.NewTicker(app.ConfigWatch)
+		defer ticker.Stop()
+		tickCh = ticker.C
+	}
+
+	sites := append([]*Site{app.DefaultSite}, app.Sites...)
+	modTimes := make([]time.Time, len(sites))
+	for i, site := range sites {
+		modTimes[i] = site.configModTime()
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sigCh:
+			app.loggers.Infof("SIGHUP received")
+			for i, site := range sites {
+				site.ReloadConfig()
+				modTimes[i] = site.configModTime()
+			}
+		case <-tickCh:
+			for i, site := range sites {
+				t := site.configModTime()
+				if !t.Equal(modTimes[i]) {
+					site.ReloadConfig()
+					modTimes[i] = site.configModTime()
+				}
+			}
+		}
+	}
+}
+
+// configModTime returns the latest modification time of the config files.
+func (site *Site) configModTime() time.Time {
+	files := []string{site.configPath}
+	if site.app.ConfigEnv != "" {
+		files = append(files, config.OverlayPath(site.configPath, site.app.ConfigEnv))
+	}
+	files = append(files, site.Config.Files...)
+	var t time.Time
+	for _, file := range files {
+		fi, err := os.Stat(file)
+		if err == nil && fi.ModTime().After(t) {
+			t = fi.ModTime()
+		}
+	}
+	return t
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/yaegashi/pswa/config"
+)
+
+const (
+	SourceDefault = "default"
+	SourceConfig  = "config"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+)
+
+// Setting is an app setting available as a command-line flag, an environment variable,
+// and optionally a key in the server section of the config file, in the order of precedence.
+type Setting struct {
+	Name   string
+	Env    string
+	Server string
+	Secret bool
+	Source string
+	Flag   *flag.Flag
+}
+
+func (app *App) NewFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	app.Settings = nil
+	add := func(s *Setting, define func(name string)) {
+		define(s.Name)
+		s.Flag = fs.Lookup(s.Name)
+		s.Source = SourceDefault
+		app.Settings = append(app.Settings, s)
+	}
+	add(&Setting{Name: "tenant-id", Env: EnvTenantID, Server: "tenantId"}, func(n string) {
+		fs.StringVar(&app.TenantID, n, "", "tenant ID of Azure AD")
+	})
+	add(&Setting{Name: "client-id", Env: EnvClientID, Server: "clientId"}, func(n string) {
+		fs.StringVar(&app.ClientID, n, "", "client ID registered in Azure AD")
+	})
+	add(&Setting{Name: "client-secret", Env: EnvClientSecret, Secret: true}, func(n string) {
+		fs.StringVar(&app.ClientSecret, n, "", "client secret generated in Azure AD")
+	})
+	add(&Setting{Name: "redirect-uri", Env: EnvRedirectURI, Server: "redirectUri"}, func(n string) {
+		fs.StringVar(&app.RedirectURI, n, "", "redirect URI specified in Azure AD")
+	})
+	add(&Setting{Name: "auth-params", Env: EnvAuthParams, Server: "authParams"}, func(n string) {
+		fs.StringVar(&app.AuthParams, n, "", "additional authorize endpoint parameters")
+	})
+	add(&Setting{Name: "session-key", Env: EnvSessionKey, Secret: true}, func(n string) {
+		fs.StringVar(&app.SessionKey, n, "", "random string to encrypt values in the cookie session store")
+	})
+	add(&Setting{Name: "listen", Env: EnvListen, Server: "listen"}, func(n string) {
+		fs.StringVar(&app.Listen, n, DefaultListen, "server address to listen")
+	})
+	add(&Setting{Name: "tls-cert", Env: EnvTLSCert, Server: "tlsCert"}, func(n string) {
+		fs.StringVar(&app.TLSCert, n, "", "TLS certificate file to serve HTTPS")
+	})
+	add(&Setting{Name: "tls-key", Env: EnvTLSKey, Server: "tlsKey"}, func(n string) {
+		fs.StringVar(&app.TLSKey, n, "", "TLS private key file to serve HTTPS")
+	})
+	add(&Setting{Name: "tls-client-ca", Env: EnvTLSClientCA, Server: "tlsClientCA"}, func(n string) {
+		fs.StringVar(&app.TLSClientCA, n, "", "CA bundle file to verify client certificates")
+	})
+	add(&Setting{Name: "www-root", Env: EnvWWWRoot}, func(n string) {
+		fs.StringVar(&app.WWWRootPath, n, DefaultWWWRoot, "web content root directory")
+	})
+	add(&Setting{Name: "test-root", Env: EnvTestRoot, Server: "testRootPath"}, func(n string) {
+		fs.StringVar(&app.TestRootPath, n, DefaultTestRoot, "web content root directory for tests")
+	})
+	add(&Setting{Name: "config", Env: EnvConfig}, func(n string) {
+		fs.StringVar(&app.ConfigPath, n, DefaultConfig, "config file, relative to www-root if not absolute")
+	})
+	add(&Setting{Name: "env", Env: EnvConfigEnv}, func(n string) {
+		fs.StringVar(&app.ConfigEnv, n, "", "environment of the config overlay")
+	})
+	add(&Setting{Name: "hosts", Env: EnvHosts}, func(n string) {
+		fs.StringVar(&app.HostsPath, n, "", "hosts file for virtual hosting")
+	})
+	add(&Setting{Name: "config-lenient", Env: EnvConfigLenient}, func(n string) {
+		fs.BoolVar(&app.ConfigLenient, n, false, "report unknown config fields as warnings")
+	})
+	add(&Setting{Name: "config-watch", Env: EnvConfigWatch, Server: "configWatch"}, func(n string) {
+		fs.DurationVar(&app.ConfigWatch, n, 0, "interval to check the config file for changes")
+	})
+	add(&Setting{Name: "dev-auth", Env: EnvDevAuth}, func(n string) {
+		fs.BoolVar(&app.DevAuth, n, false, "enable the development identity provider (NEVER in production)")
+	})
+	add(&Setting{Name: "strict", Env: EnvStrict}, func(n string) {
+		fs.BoolVar(&app.Strict, n, DefaultStrict, "serve 503 on config or OIDC failure")
+	})
+	return fs
+}
+
+// ParseSettings parses command-line flags, then environment variables for unset flags.
+func (app *App) ParseSettings(fs *flag.FlagSet, args []string) error {
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	fs.Visit(func(f *flag.Flag) {
+		for _, s := range app.Settings {
+			if s.Flag == f {
+				s.Source = SourceFlag
+			}
+		}
+	})
+	for _, s := range app.Settings {
+		if s.Source != SourceDefault {
+			continue
+		}
+		v := os.Getenv(s.Env)
+		if v == "" {
+			continue
+		}
+		err := s.Flag.Value.Set(v)
+		if err != nil {
+			return fmt.Errorf("Invalid %s: %w", s.Env, err)
+		}
+		s.Source = SourceEnv
+	}
+	return nil
+}
+
+// ApplyServerConfig sets settings neither in flags nor in environment variables
+// from the server section of the config file.
+func (app *App) ApplyServerConfig(server *config.Server) error {
+	if server == nil {
+		return nil
+	}
+	var values map[string]string
+	b, _ := json.Marshal(server)
+	json.Unmarshal(b, &values)
+	for _, s := range app.Settings {
+		v := values[s.Server]
+		if s.Server == "" || s.Source != SourceDefault || v == "" {
+			continue
+		}
+		err := s.Flag.Value.Set(v)
+		if err != nil {
+			return fmt.Errorf("Invalid server.%s: %w", s.Server, err)
+		}
+		s.Source = SourceConfig
+	}
+	return nil
+}
+
+func printConfigMain(args []string) int {
+	app := &App{}
+	fs := app.NewFlagSet("print-config")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: pswa print-config [options]\n")
+		fs.PrintDefaults()
+	}
+	err := app.ParseSettings(fs, args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	site := &Site{WWWRootPath: app.WWWRootPath, ConfigPath: app.ConfigPath}
+	site.ResolveConfigPath()
+	loader := &config.Loader{Lenient: app.ConfigLenient, Environment: app.ConfigEnv}
+	cfg, ps := loader.Load(site.configPath)
+	for _, p := range ps {
+		fmt.Fprintln(os.Stderr, p)
+	}
+	if cfg != nil {
+		err = app.ApplyServerConfig(cfg.Server)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	for _, s := range app.Settings {
+		v := s.Flag.Value.String()
+		if s.Secret && v != "" {
+			v = "<redacted>"
+		}
+		fmt.Printf("%-20s %-20s = %q (%s)\n", s.Name, s.Env, v, s.Source)
+	}
+	return 0
+}
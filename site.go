@@ -0,0 +1,154 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"github.com/yaegashi/pswa/auth"
+	"github.com/yaegashi/pswa/config"
+	"github.com/yaegashi/pswa/core"
+	"go.uber.org/zap"
+)
+
+// Site serves a web root with its own config and auth for the default host or a host pattern.
+type Site struct {
+	Host         string
+	TenantID     string
+	ClientID     string
+	ClientSecret string
+	RedirectURI  string
+	AuthParams   string
+	WWWRootPath  string
+	TestRootPath string
+	ConfigPath   string
+	Config       *config.Config
+	Auth         *auth.Auth
+	Core         *core.Core
+	Handler      http.Handler
+	app          *App
+	loggers      *zap.SugaredLogger
+	configPath   string
+	configFailed atomic.Bool
+	oidcFailed   atomic.Bool
+}
+
+func (site *Site) ResolveConfigPath() {
+	site.configPath = site.ConfigPath
+	if !filepath.IsAbs(site.configPath) {
+		site.configPath = filepath.Join(site.WWWRootPath, site.configPath)
+	}
+}
+
+func (site *Site) ReadConfig() {
+	app, loggers := site.app, site.loggers
+	site.ResolveConfigPath()
+	loggers.Infof("Reading config: %s", site.configPath)
+	if app.ConfigEnv != "" {
+		loggers.Infof("Reading config overlay: %s", config.OverlayPath(site.configPath, app.ConfigEnv))
+	}
+	var err error
+	site.Config, err = site.LoadConfig()
+	if err != nil {
+		loggers.Errorf("Reading config failed: %s", err)
+		if app.Strict {
+			site.Config = &config.Config{}
+			site.configFailed.Store(true)
+		} else {
+			loggers.Warnf("Strict mode disabled, falling back to unconfigured mode")
+			site.Config = config.Unconfigured
+		}
+	}
+}
+
+func (site *Site) Setup() {
+	app, loggers := site.app, site.loggers
+
+	site.Auth = auth.New(site.Config, app.SessionStore)
+	site.Auth.SessionName = auth.SiteSessionName(site.Host)
+	site.Auth.DevAuth = app.DevAuth
+	site.Auth.TokenCodecs = auth.NewTokenCodecs([]byte(app.SessionKey))
+	loggers.Infof("OpenID Connect auth config:")
+	loggers.Infof("  TenantID    = %s", site.TenantID)
+	loggers.Infof("  ClientID    = %s", site.ClientID)
+	if site.RedirectURI == "" && site.Host != "" {
+		loggers.Infof("  RedirectURI = https://<request host>%s", auth.CallbackHandlerPath)
+	} else {
+		loggers.Infof("  RedirectURI = %s", site.RedirectURI)
+	}
+	loggers.Infof("  AuthParams  = %s", site.AuthParams)
+
+	if site.Auth.DevAuth {
+		loggers.Warnf("DEVELOPMENT IDENTITY PROVIDER ENABLED at %s: anyone can sign in as any user with any roles.  NEVER use it in production!", auth.DevLoginHandlerPath)
+	}
+
+	if site.Auth.EasyAuth {
+		loggers.Infof("EasyAuth enabled, skipping OpenID Connect auth config")
+	} else if site.Auth.DevAuth {
+		loggers.Infof("Development identity provider enabled, skipping OpenID Connect auth config")
+	} else if site.TenantID == "" && site.ClientID == "" && site.Config.BasicAuth != nil {
+		loggers.Infof("Basic auth enabled, skipping OpenID Connect auth config")
+	} else if site.TenantID == "" || site.ClientID == "" || site.ClientSecret == "" || (site.RedirectURI == "" && site.Host == "") {
+		loggers.Errorf("OpenID Connect auth config missing")
+		site.oidcFailed.Store(app.Strict)
+	} else {
+		err := site.Auth.ConfigureOIDC(site.TenantID, site.ClientID, site.ClientSecret, site.RedirectURI, site.AuthParams)
+		if err != nil {
+			loggers.Errorf("OpenID Connect auth config failed: %s", err)
+			site.oidcFailed.Store(app.Strict)
+		} else if site.Auth.MultiTenant && (site.Config.SignIn == nil || site.Config.SignIn.Tenants == nil) {
+			loggers.Warnf("Multi-tenant sign-in with %q accepts users of any tenant: set signIn.tenants to restrict", site.TenantID)
+		}
+	}
+
+	root := site.WWWRootPath
+	if site.Config.TestRoot {
+		loggers.Warnf("TestRoot enabled")
+		root = site.TestRootPath
+	}
+	loggers.Infof("Serving from root path %s", root)
+	site.Core = core.New(root, site.Config, site.Auth)
+
+	if site.Unavailable() {
+		loggers.Errorf("Strict mode enabled, serving 503 Service Unavailable until the problems above are fixed")
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(HealthPath, site.HealthHandler)
+	mux.HandleFunc(core.ExplainHandlerPath, site.Core.ExplainHandler)
+	site.Auth.RegisterHandlers(mux)
+
+	coreHandler := site.Core.FileHandler
+	if site.Config.TestHandler {
+		coreHandler = site.Core.TestHandler
+	}
+	mux.Handle("/", site.Core.NewMiddleware()(http.HandlerFunc(coreHandler)))
+
+	site.Handler = site.StrictMiddleware(mux)
+}
+
+// hostName returns the lower-case host name of r without the port.
+func hostName(r *http.Request) string {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
+
+// HostHandler dispatches requests to the first site matching the Host header,
+// or to the default site.
+func (app *App) HostHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := hostName(r)
+		for i, h := range app.Hosts {
+			if h.Match(host) {
+				app.Sites[i].Handler.ServeHTTP(w, r)
+				return
+			}
+		}
+		app.DefaultSite.Handler.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"net/http"
+)
+
+func (site *Site) Unavailable() bool {
+	return site.configFailed.Load() || site.oidcFailed.Load()
+}
+
+func (site *Site) StrictMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if site.Unavailable() && r.URL.Path != HealthPath {
+			w.Header().Set("Cache-Control", "no-cache")
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (site *Site) HealthHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-cache")
+	if site.Unavailable() {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte("OK\n"))
+}
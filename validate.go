@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/yaegashi/pswa/config"
+)
+
+func validateMain(args []string) int {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: pswa validate [options] <file>...\n")
+		fs.PrintDefaults()
+	}
+	werror := fs.Bool("warnings-as-errors", false, "exit non-zero on warnings")
+	lenient := fs.Bool("lenient", false, "report unknown fields as warnings")
+	env := fs.String("env", os.Getenv(EnvConfigEnv), "environment of the config overlay")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+	loader := &config.Loader{Lenient: *lenient, Environment: *env}
+	nerr, nwarn := 0, 0
+	for _, file := range fs.Args() {
+		_, ps := loader.Load(file)
+		for _, p := range ps {
+			if p.File == "" {
+				p.File = file
+			}
+			fmt.Println(p)
+		}
+		nerr += len(ps.Errors())
+		nwarn += len(ps.Warnings())
+	}
+	fmt.Fprintf(os.Stderr, "%d error(s), %d warning(s)\n", nerr, nwarn)
+	if nerr > 0 || (*werror && nwarn > 0) {
+		return 1
+	}
+	return 0
+}
+
+func schemaMain(args []string) int {
+	b, _ := json.MarshalIndent(config.Schema(), "", "  ")
+	fmt.Println(string(b))
+	return 0
+}